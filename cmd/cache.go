@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/gerdou/awsx/utilities"
+	"github.com/spf13/cobra"
+)
+
+var cacheMigrateTo string
+var cacheMigrateKeyFile string
+var cacheMigrateKeyCommand string
+
+var cacheCmd = &cobra.Command{
+	Use:               "cache",
+	Short:             "Manages the access token cache",
+	Long:              `Manages the access token cache`,
+	DisableAutoGenTag: true,
+}
+
+var cacheMigrateCmd = &cobra.Command{
+	Use:               "migrate",
+	Short:             "Moves the access token cache to another storage backend",
+	Long:              `Rewrites the access token cache with another storage backend and saves the backend in the awsx configuration. The encrypted backend derives its key from the output of --key-command, the content of --key-file or the AWSX_CACHE_PASSPHRASE environment variable.`,
+	Example:           "awsx cache migrate --to encrypted --key-command \"pass show awsx\"",
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.Contains(internal.CacheBackends, cacheMigrateTo) {
+			return fmt.Errorf("unknown cache backend %q, must be one of %s", cacheMigrateTo, strings.Join(internal.CacheBackends, ", "))
+		}
+		if cacheMigrateTo == internal.CacheBackendPlaintext && (cacheMigrateKeyFile != "" || cacheMigrateKeyCommand != "") {
+			return fmt.Errorf("--key-file and --key-command only apply to the %s backend", internal.CacheBackendEncrypted)
+		}
+		if cacheMigrateKeyFile != "" && cacheMigrateKeyCommand != "" {
+			return fmt.Errorf("--key-file and --key-command are mutually exclusive")
+		}
+
+		if cacheMigrateKeyFile != "" {
+			var err error
+			cacheMigrateKeyFile, err = utilities.AbsolutePath(cacheMigrateKeyFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := internal.MigrateCache(&internal.CacheSettings{
+			Backend:    cacheMigrateTo,
+			KeyFile:    cacheMigrateKeyFile,
+			KeyCommand: cacheMigrateKeyCommand,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("The access token cache now uses the %s backend\n", cacheMigrateTo)
+		return nil
+	},
+}
+
+func init() {
+	cacheMigrateCmd.Flags().StringVar(&cacheMigrateTo, "to", "", fmt.Sprintf("Backend to migrate to (%s)", strings.Join(internal.CacheBackends, ", ")))
+	cacheMigrateCmd.Flags().StringVar(&cacheMigrateKeyFile, "key-file", "", "File containing the key of the encrypted backend")
+	cacheMigrateCmd.Flags().StringVar(&cacheMigrateKeyCommand, "key-command", "", "Command printing the key of the encrypted backend")
+	_ = cacheMigrateCmd.MarkFlagRequired("to")
+	cacheCmd.AddCommand(cacheMigrateCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var addProfileRegion string
+var addProfileAccount string
+var addProfileAccountName string
+var addProfileRole string
+
+var configAddProfileCmd = &cobra.Command{
+	Use:               "add-profile <config-name> <profile-name>",
+	Short:             "Adds a profile to an awsx config without prompting",
+	Long:              `Adds a profile to an awsx config from flags, or changes the given flags of an existing profile. The config must exist, see "awsx config set".`,
+	Example:           "awsx config add-profile work prod --region us-east-1 --account 123456789012 --account-name prod --role Admin",
+	DisableAutoGenTag: true,
+	Args:              cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configName, profileName := args[0], args[1]
+
+		err := internal.UpdateInternalConfig(func(configs map[string]*internal.Config) error {
+			config, ok := configs[configName]
+			if !ok {
+				return fmt.Errorf("config %s not found, create it with \"awsx config set %s\" first", configName, configName)
+			}
+			if config.Profiles == nil {
+				config.Profiles = make(map[string]*internal.Profile)
+			}
+
+			profile, ok := config.Profiles[profileName]
+			if !ok {
+				profile = &internal.Profile{Name: profileName}
+			}
+
+			if !ok && !cmd.Flags().Changed("region") {
+				return fmt.Errorf("--region is required for new profiles")
+			}
+			if cmd.Flags().Changed("region") {
+				profile.Region = addProfileRegion
+			}
+			err := internal.ValidateRegion(profile.Region)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("account") || cmd.Flags().Changed("account-name") || cmd.Flags().Changed("role") {
+				defaultAccount := &internal.UsageInformation{}
+				if profile.DefaultAccount != nil {
+					*defaultAccount = *profile.DefaultAccount
+				}
+				if cmd.Flags().Changed("account") {
+					defaultAccount.AccountId = addProfileAccount
+				}
+				if cmd.Flags().Changed("account-name") {
+					defaultAccount.AccountName = addProfileAccountName
+				}
+				if cmd.Flags().Changed("role") {
+					defaultAccount.Role = addProfileRole
+				}
+
+				err = internal.ValidateAccountId(defaultAccount.AccountId)
+				if err != nil {
+					return err
+				}
+				if defaultAccount.AccountName == "" {
+					return fmt.Errorf("--account-name is required with a default account")
+				}
+				profile.DefaultAccount = defaultAccount
+			}
+
+			config.Profiles[profileName] = profile
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Saved profile %s in config %s\n", profileName, configName)
+		return nil
+	},
+}
+
+func init() {
+	configAddProfileCmd.Flags().StringVarP(&addProfileRegion, "region", "r", "", "Default region of the profile, required for new profiles")
+	configAddProfileCmd.Flags().StringVar(&addProfileAccount, "account", "", "ID of the default account of the profile, 12 digits")
+	configAddProfileCmd.Flags().StringVar(&addProfileAccountName, "account-name", "", "Name of the default account of the profile")
+	configAddProfileCmd.Flags().StringVar(&addProfileRole, "role", "", "Default role of the profile")
+	configCmd.AddCommand(configAddProfileCmd)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gerdou/awsx/cmd/internal"
+)
+
+// writeTestConfig writes the config work with the profile dev.
+func writeTestConfig(t *testing.T) {
+	t.Helper()
+	err := internal.WriteInternalConfig(map[string]*internal.Config{
+		"work": {
+			Id:        "d-1234567890",
+			SsoRegion: "eu-west-1",
+			Complete:  true,
+			Profiles: map[string]*internal.Profile{
+				"dev": {
+					Region:         "eu-west-1",
+					DefaultAccount: &internal.UsageInformation{AccountId: "123456789012", AccountName: "dev", Role: "Admin"},
+					Output:         internal.ProfileOutputSsoSession,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestProfile(t *testing.T, profileName string) *internal.Profile {
+	t.Helper()
+	configs, err := internal.ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configs["work"].Profiles[profileName]
+}
+
+func TestConfigAddProfile(t *testing.T) {
+	useTempConfigDir(t)
+	writeTestConfig(t)
+
+	err := runCommand(t, configAddProfileCmd, "work", "prod", "--region", "us-east-1", "--account", "210987654321", "--account-name", "prod", "--role", "ReadOnly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.UsageInformation{AccountId: "210987654321", AccountName: "prod", Role: "ReadOnly"}
+	if profile := readTestProfile(t, "prod"); profile.Region != "us-east-1" || !reflect.DeepEqual(profile.DefaultAccount, want) {
+		t.Errorf("profile prod = %+v with the default account %+v", profile, profile.DefaultAccount)
+	}
+
+	err = runCommand(t, configAddProfileCmd, "work", "sandbox", "-r", "eu-central-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile := readTestProfile(t, "sandbox"); profile.Region != "eu-central-1" || profile.DefaultAccount != nil {
+		t.Errorf("profile sandbox = %+v, want no default account", profile)
+	}
+}
+
+func TestConfigAddProfileChangesOnlyGivenFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantRegion  string
+		wantAccount internal.UsageInformation
+	}{
+		{
+			name:        "region",
+			args:        []string{"--region", "eu-central-1"},
+			wantRegion:  "eu-central-1",
+			wantAccount: internal.UsageInformation{AccountId: "123456789012", AccountName: "dev", Role: "Admin"},
+		},
+		{
+			name:        "role",
+			args:        []string{"--role", "ReadOnly"},
+			wantRegion:  "eu-west-1",
+			wantAccount: internal.UsageInformation{AccountId: "123456789012", AccountName: "dev", Role: "ReadOnly"},
+		},
+		{
+			name:        "account",
+			args:        []string{"--account", "210987654321", "--account-name", "staging"},
+			wantRegion:  "eu-west-1",
+			wantAccount: internal.UsageInformation{AccountId: "210987654321", AccountName: "staging", Role: "Admin"},
+		},
+		{
+			name:        "empty role",
+			args:        []string{"--role", ""},
+			wantRegion:  "eu-west-1",
+			wantAccount: internal.UsageInformation{AccountId: "123456789012", AccountName: "dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempConfigDir(t)
+			writeTestConfig(t)
+
+			err := runCommand(t, configAddProfileCmd, append([]string{"work", "dev"}, tt.args...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			profile := readTestProfile(t, "dev")
+			if profile.Region != tt.wantRegion {
+				t.Errorf("region = %s, want %s", profile.Region, tt.wantRegion)
+			}
+			if profile.DefaultAccount == nil || *profile.DefaultAccount != tt.wantAccount {
+				t.Errorf("default account = %+v, want %+v", profile.DefaultAccount, tt.wantAccount)
+			}
+			if profile.Output != internal.ProfileOutputSsoSession {
+				t.Errorf("output = %q was not kept", profile.Output)
+			}
+		})
+	}
+}
+
+func TestConfigAddProfileRejectsInvalidFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown config", args: []string{"home", "dev", "--region", "eu-west-1"}},
+		{name: "new profile without region", args: []string{"work", "prod", "--account", "210987654321", "--account-name", "prod"}},
+		{name: "invalid region", args: []string{"work", "dev", "--region", "eu-west"}},
+		{name: "invalid account", args: []string{"work", "dev", "--account", "12345"}},
+		{name: "account without name", args: []string{"work", "prod", "--region", "eu-west-1", "--account", "210987654321"}},
+		{name: "role without account", args: []string{"work", "prod", "--region", "eu-west-1", "--role", "Admin"}},
+		{name: "missing profile name", args: []string{"work", "--region", "eu-west-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempConfigDir(t)
+			writeTestConfig(t)
+			before, err := internal.ReadInternalConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = runCommand(t, configAddProfileCmd, tt.args...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			after, _ := internal.ReadInternalConfig()
+			if !reflect.DeepEqual(after, before) {
+				t.Errorf("config changed to %+v", after["work"])
+			}
+		})
+	}
+}
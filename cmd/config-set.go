@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var setStartUrlId string
+var setStartUrl string
+var setSsoRegion string
+var setPartition string
+
+var configSetCmd = &cobra.Command{
+	Use:               "set [config-name]",
+	Short:             "Creates or updates an awsx config without prompting",
+	Long:              `Creates or updates an awsx config from flags. Only the given flags are changed on an existing config. Add profiles to it with "awsx config add-profile".`,
+	Example:           "awsx config set work --start-url-id d-1234567890 --sso-region eu-west-1",
+	DisableAutoGenTag: true,
+	Args:              cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configName := "default"
+		if len(args) > 0 {
+			configName = args[0]
+		}
+
+		if cmd.Flags().Changed("start-url-id") && cmd.Flags().Changed("start-url") {
+			return fmt.Errorf("--start-url-id and --start-url are mutually exclusive")
+		}
+
+		err := internal.UpdateInternalConfig(func(configs map[string]*internal.Config) error {
+			config, ok := configs[configName]
+			if !ok {
+				config = &internal.Config{Profiles: make(map[string]*internal.Profile)}
+			}
+			config.Name = configName
+			config.Complete = true
+
+			if cmd.Flags().Changed("start-url-id") {
+				config.Id, config.StartUrl = setStartUrlId, ""
+			}
+			if cmd.Flags().Changed("start-url") {
+				config.Id, config.StartUrl = "", setStartUrl
+			}
+			if cmd.Flags().Changed("sso-region") {
+				config.SsoRegion = setSsoRegion
+			}
+			if cmd.Flags().Changed("partition") {
+				config.Partition = setPartition
+			}
+
+			err := config.Validate()
+			if err != nil {
+				return err
+			}
+
+			configs[configName] = config
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Saved config %s\n", configName)
+		return nil
+	},
+}
+
+func init() {
+	configSetCmd.Flags().StringVar(&setStartUrlId, "start-url-id", "", "Id of the start URL, e.g. d-1234567890")
+	configSetCmd.Flags().StringVar(&setStartUrl, "start-url", "", "Full start URL, e.g. for a custom domain")
+	configSetCmd.Flags().StringVar(&setSsoRegion, "sso-region", "", "Region of AWS SSO")
+	configSetCmd.Flags().StringVar(&setPartition, "partition", "", fmt.Sprintf("Partition of the start URL (%s), defaults to the partition of the SSO region", strings.Join(internal.Partitions, ", ")))
+	configCmd.AddCommand(configSetCmd)
+}
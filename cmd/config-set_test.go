@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/gerdou/awsx/cmd/internal"
+)
+
+func TestConfigSet(t *testing.T) {
+	useTempConfigDir(t)
+
+	err := runCommand(t, configSetCmd, "work", "--start-url-id", "d-1234567890", "--sso-region", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runCommand(t, configAddProfileCmd, "work", "dev", "--region", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the given flags change, the start URL and the profiles are kept
+	err = runCommand(t, configSetCmd, "work", "--sso-region", "eu-central-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, err := internal.ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := configs["work"]
+	if config.Id != "d-1234567890" || config.SsoRegion != "eu-central-1" || !config.Complete {
+		t.Errorf("config = %+v, want the id d-1234567890 and the SSO region eu-central-1", config)
+	}
+	if _, exists := config.Profiles["dev"]; !exists {
+		t.Errorf("profiles = %v, want dev to be kept", config.Profiles)
+	}
+
+	// A start URL replaces the id
+	err = runCommand(t, configSetCmd, "work", "--start-url", "https://sso.example.com/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ = internal.ReadInternalConfig()
+	if config := configs["work"]; config.Id != "" || config.StartUrl != "https://sso.example.com/start" || config.SsoRegion != "eu-central-1" {
+		t.Errorf("config = %+v, want only the start URL", config)
+	}
+}
+
+func TestConfigSetDefaultConfigName(t *testing.T) {
+	useTempConfigDir(t)
+	err := runCommand(t, configSetCmd, "--start-url-id", "d-1234567890", "--sso-region", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ := internal.ReadInternalConfig()
+	if _, exists := configs["default"]; !exists {
+		t.Errorf("configs = %v, want default", configs)
+	}
+}
+
+func TestConfigSetRejectsInvalidFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "start url id and start url", args: []string{"work", "--start-url-id", "d-1234567890", "--start-url", "https://sso.example.com/start", "--sso-region", "eu-west-1"}},
+		{name: "no start url", args: []string{"work", "--sso-region", "eu-west-1"}},
+		{name: "no sso region", args: []string{"work", "--start-url-id", "d-1234567890"}},
+		{name: "invalid sso region", args: []string{"work", "--start-url-id", "d-1234567890", "--sso-region", "west"}},
+		{name: "start url without https", args: []string{"work", "--start-url", "http://sso.example.com/start", "--sso-region", "eu-west-1"}},
+		{name: "unknown partition", args: []string{"work", "--start-url-id", "d-1234567890", "--sso-region", "eu-west-1", "--partition", "aws-iso"}},
+		{name: "region outside the partition", args: []string{"work", "--start-url-id", "d-1234567890", "--sso-region", "eu-west-1", "--partition", "aws-cn"}},
+		{name: "two config names", args: []string{"work", "home", "--start-url-id", "d-1234567890", "--sso-region", "eu-west-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempConfigDir(t)
+			err := runCommand(t, configSetCmd, tt.args...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			configs, _ := internal.ReadInternalConfig()
+			if len(configs) != 0 {
+				t.Errorf("configs = %v, want none to be written", configs)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/spf13/cobra"
 	"github.com/gerdou/awsx/cmd/internal"
 	"github.com/gerdou/awsx/utilities"
 	"log"
 	"sort"
+	"strings"
 )
 
 var configCmd = &cobra.Command{
@@ -19,7 +22,11 @@ var configCmd = &cobra.Command{
 		if len(args) > 0 {
 			configNames = args
 		}
-		return configArgs(configNames)
+		prompt, err := newPrompt()
+		if err != nil {
+			return err
+		}
+		return configArgs(configNames, prompt)
 	},
 }
 
@@ -27,13 +34,13 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 }
 
-func configArgs(configNames []string) error {
+func configArgs(configNames []string, prompter internal.Prompt) error {
 	configs, _ := internal.ReadInternalConfig()
 	if configs == nil || len(configs) == 0 {
 		configs = make(map[string]*internal.Config)
 	}
-	prompter := internal.Prompter{}
 
+	configured := make(map[string]*internal.Config)
 	for _, configName := range configNames {
 		if configName == "" {
 			continue
@@ -49,14 +56,31 @@ func configArgs(configNames []string) error {
 		}
 		config.Complete = false
 
-		var err error
-		config.Id, err = prompter.Prompt("Start URL Id", config.Id)
+		startUrlOrId := config.Id
+		if config.StartUrl != "" {
+			startUrlOrId = config.StartUrl
+		}
+
+		startUrlOrId, err := prompter.Prompt("Start URL or Id", startUrlOrId)
+		if isUnanswerable(err) {
+			return err
+		}
 		if err != nil {
-			log.Printf("Failed to prompt for start URL Id for %s\n", configName)
+			log.Printf("Failed to prompt for start URL or Id for %s\n", configName)
 			continue
 		}
 
+		// A full URL is kept as is, for vanity domains and start URLs outside of the aws partition
+		if strings.Contains(startUrlOrId, "://") {
+			config.Id, config.StartUrl = "", startUrlOrId
+		} else {
+			config.Id, config.StartUrl = startUrlOrId, ""
+		}
+
 		config.SsoRegion, err = prompter.Prompt("SSO Region", config.SsoRegion)
+		if isUnanswerable(err) {
+			return err
+		}
 		if err != nil {
 			log.Printf("Failed to prompt for sso region for %s\n", configName)
 			continue
@@ -67,6 +91,12 @@ func configArgs(configNames []string) error {
 			continue
 		}
 
+		config.Name = configName
+		if err = config.Validate(); err != nil {
+			log.Println(err)
+			continue
+		}
+
 		profileNames := utilities.Keys(config.Profiles)
 		sort.SliceStable(profileNames, func(i, j int) bool {
 			return profileNames[i] < profileNames[j]
@@ -81,6 +111,9 @@ func configArgs(configNames []string) error {
 				defaultProfileName = profileNames[0]
 			}
 			profileName, err = prompter.Prompt("Profile name to configure", defaultProfileName)
+			if isUnanswerable(err) {
+				return err
+			}
 			if err != nil {
 				log.Printf("Failed to prompt for %s config argument: %s\n", configName, err)
 				break
@@ -94,14 +127,17 @@ func configArgs(configNames []string) error {
 				profileNames = profileNames[1:]
 			}
 
-			defaultRegion, found := config.Profiles[profileName]
+			profile, found := config.Profiles[profileName]
 			if !found {
-				defaultRegion = &internal.Profile{
+				profile = &internal.Profile{
 					Region: "",
 				}
 			}
 
-			region, err = prompter.Prompt("Default Profile Region", defaultRegion.Region)
+			region, err = prompter.Prompt("Default Profile Region", profile.Region)
+			if isUnanswerable(err) {
+				return err
+			}
 			if err != nil {
 				log.Printf("Failed to prompt for region for %s: %s\n", configName, err)
 				break
@@ -110,21 +146,34 @@ func configArgs(configNames []string) error {
 				log.Println("Region name cannot be empty")
 				break
 			}
-
-			config.Profiles[profileName] = &internal.Profile{
-				Region:         region,
-				DefaultAccount: nil,
+			if err = internal.ValidateRegion(region); err != nil {
+				log.Println(err)
+				break
 			}
 
-			index, _, _ := prompter.Select("Do you wish to configure a default account for this profile?", []string{"Yes", "No"}, nil)
-			if index == 0 {
-				config.Profiles[profileName].DefaultAccount = configDefaultAccountForProfile(profileName, prompter)
+			// Only the region and the default account are asked for, other settings of the profile are kept
+			profile.Region = region
+			profile.DefaultAccount = nil
+			config.Profiles[profileName] = profile
+
+			configureDefaultAccount, err := confirm(prompter, "Do you wish to configure a default account for this profile?")
+			if err != nil {
+				return err
+			}
+			if configureDefaultAccount {
+				profile.DefaultAccount, err = configDefaultAccountForProfile(profileName, prompter)
+				if err != nil {
+					return err
+				}
 			}
 
 			profilesConfigured++
 
-			index, _, _ = prompter.Select("Do you wish to add another profile to this config?", []string{"Yes", "No"}, nil)
-			if index != 0 {
+			addProfile, err := confirm(prompter, "Do you wish to add another profile to this config?")
+			if err != nil {
+				return err
+			}
+			if !addProfile {
 				break
 			}
 		}
@@ -135,41 +184,81 @@ func configArgs(configNames []string) error {
 
 		config.Complete = true
 		configs[configName] = config
+		configured[configName] = config
 	}
 
-	return internal.WriteInternalConfig(configs)
+	if len(configured) == 0 {
+		return nil
+	}
+
+	// Only the configs completed here are written, on top of the latest configuration file
+	return internal.UpdateInternalConfig(func(current map[string]*internal.Config) error {
+		for configName, config := range configured {
+			current[configName] = config
+		}
+		return nil
+	})
+}
+
+// isUnanswerable tells whether a prompt failed because it cannot be answered at all, with --no-input or
+// when the answers file has no answer for it, so that the wizard stops instead of skipping the question.
+func isUnanswerable(err error) bool {
+	return errors.Is(err, internal.ErrNoInput) || errors.Is(err, internal.ErrNoAnswer)
+}
+
+// confirm asks a yes or no question. Answers that fail for another reason than being unanswerable are logged
+// and count as no.
+func confirm(prompter internal.Prompt, label string) (bool, error) {
+	index, value, err := prompter.Select(label, []string{"Yes", "No"}, nil)
+	if isUnanswerable(err) {
+		return false, err
+	}
+	if err != nil {
+		log.Printf("Failed to prompt for %q, assuming no: %s\n", label, err)
+		return false, nil
+	}
+	return index == 0 && value == "Yes", nil
 }
 
-func configDefaultAccountForProfile(profile string, prompter internal.Prompter) *internal.UsageInformation {
+func configDefaultAccountForProfile(profile string, prompter internal.Prompt) (*internal.UsageInformation, error) {
 	defaultAccount := &internal.UsageInformation{}
 
 	var err error
 	defaultAccount.AccountId, err = prompter.Prompt("Default Account Id for this profile", "")
+	if isUnanswerable(err) {
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("Failed to prompt for default account id for profile: %s\n", err)
-		return nil
+		return nil, nil
 	}
 
 	if defaultAccount.AccountId == "" {
 		log.Println("Default Account Id cannot be empty")
-		return nil
+		return nil, nil
 	}
 
 	defaultAccount.AccountName, err = prompter.Prompt("Default Account Name for this profile", "")
+	if isUnanswerable(err) {
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("Failed to prompt for default account name for profile: %s\n", err)
-		return nil
+		return nil, nil
 	}
 
 	if defaultAccount.AccountName == "" {
 		log.Println("Default Account Name cannot be empty")
-		return nil
+		return nil, nil
 	}
 
 	defaultAccount.Role, err = prompter.Prompt("Default Role for this profile (optional)", "")
+	if isUnanswerable(err) {
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("Failed to prompt for default role for profile: %s\n", err)
 	}
 
-	return defaultAccount
+	return defaultAccount, nil
 }
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// useTempConfigDir points the awsx and AWS configuration files to a temporary directory.
+func useTempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("AWSX_HOME", filepath.Join(dir, "awsx"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "aws", "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "aws", "credentials"))
+	return dir
+}
+
+// scriptedPrompter returns a prompter answering from the given YAML answers.
+func scriptedPrompter(t *testing.T, answers string) *internal.ScriptedPrompter {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "answers.yaml")
+	err := os.WriteFile(fileName, []byte(answers), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scriptedPrompterFromFile(t, fileName)
+}
+
+// scriptedPrompterFromFile returns a prompter answering from an answers file.
+func scriptedPrompterFromFile(t *testing.T, fileName string) *internal.ScriptedPrompter {
+	t.Helper()
+	prompter, err := internal.NewScriptedPrompter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prompter
+}
+
+// selectPrompter answers every select with the item at index, or with err.
+type selectPrompter struct {
+	internal.NoInputPrompter
+	index int
+	err   error
+}
+
+func (p selectPrompter) Select(label string, toSelect []string, searcher func(input string, index int) bool) (int, string, error) {
+	if p.err != nil {
+		return 0, "", p.err
+	}
+	return p.index, toSelect[p.index], nil
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompter selectPrompter
+		want     bool
+		wantErr  error
+	}{
+		{name: "yes", prompter: selectPrompter{index: 0}, want: true},
+		{name: "no", prompter: selectPrompter{index: 1}},
+		{name: "interrupted", prompter: selectPrompter{err: errors.New("^C")}},
+		{name: "no input", prompter: selectPrompter{err: internal.ErrNoInput}, wantErr: internal.ErrNoInput},
+		{name: "no scripted answer", prompter: selectPrompter{err: internal.ErrNoAnswer}, wantErr: internal.ErrNoAnswer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := confirm(tt.prompter, "Continue?")
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("confirm() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("confirm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigArgsKeepsProfileSettings(t *testing.T) {
+	useTempConfigDir(t)
+	err := internal.WriteInternalConfig(map[string]*internal.Config{
+		"work": {
+			Id:        "d-1234567890",
+			SsoRegion: "eu-west-1",
+			Complete:  true,
+			Profiles: map[string]*internal.Profile{
+				"dev": {
+					Region:         "eu-west-1",
+					DefaultAccount: &internal.UsageInformation{AccountId: "123456789012", AccountName: "dev"},
+					Output:         internal.ProfileOutputSsoSession,
+					Synced:         true,
+					SyncHash:       "abc",
+					AssumeRole:     internal.AssumeRoleChain{{RoleArn: "arn:aws:iam::210987654321:role/Deploy"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompter := scriptedPrompter(t, `
+- label: Start URL or Id
+- label: SSO Region
+- label: Profile name to configure
+  answer: dev
+- label: Default Profile Region
+  answer: eu-central-1
+- label: Do you wish to configure a default account for this profile?
+  answer: "No"
+- label: Do you wish to add another profile to this config?
+  answer: "No"
+`)
+	err = configArgs([]string{"work"}, prompter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := internal.ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := configs["work"].Profiles["dev"]
+	if profile.Region != "eu-central-1" || profile.DefaultAccount != nil {
+		t.Errorf("region = %q and default account = %v, want eu-central-1 and none", profile.Region, profile.DefaultAccount)
+	}
+	if profile.Output != internal.ProfileOutputSsoSession || !profile.Synced || profile.SyncHash != "abc" {
+		t.Errorf("output = %q, synced = %v and sync_hash = %q were not kept", profile.Output, profile.Synced, profile.SyncHash)
+	}
+	if len(profile.AssumeRole) != 1 || profile.AssumeRole[0].RoleArn != "arn:aws:iam::210987654321:role/Deploy" {
+		t.Errorf("assume_role = %v was not kept", profile.AssumeRole)
+	}
+}
+
+func TestConfigArgsRecordAndReplay(t *testing.T) {
+	useTempConfigDir(t)
+	recorded := filepath.Join(t.TempDir(), "recorded.yaml")
+	recorder := &internal.RecordingPrompter{
+		Prompter: scriptedPrompter(t, `
+- label: Start URL or Id
+  answer: d-1234567890
+- label: SSO Region
+  answer: eu-west-1
+- label: Profile name to configure
+  answer: dev
+- label: Default Profile Region
+  answer: eu-central-1
+- label: Do you wish to configure a default account for this profile?
+  answer: "Yes"
+- label: Default Account Id for this profile
+  answer: "123456789012"
+- label: Default Account Name for this profile
+  answer: dev
+- label: Default Role for this profile (optional)
+  answer: Admin
+- label: Do you wish to add another profile to this config?
+  answer: "Yes"
+- label: Profile name to configure
+  answer: prod
+- label: Default Profile Region
+  answer: us-east-1
+- label: Do you wish to configure a default account for this profile?
+  answer: "No"
+- label: Do you wish to add another profile to this config?
+  answer: "No"
+`),
+		FileName: recorded,
+	}
+	err := configArgs([]string{"work"}, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := internal.ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying on a new machine writes the same configuration
+	useTempConfigDir(t)
+	err = configArgs([]string{"work"}, scriptedPrompterFromFile(t, recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := internal.ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed configuration %+v, want %+v", got["work"], want["work"])
+	}
+	if profile := got["work"].Profiles["dev"]; profile.Region != "eu-central-1" || profile.DefaultAccount == nil || profile.DefaultAccount.Role != "Admin" {
+		t.Fatalf("profile dev = %+v", profile)
+	}
+}
+
+// runCommand runs a command with the given arguments, starting from the flag defaults like a new process would.
+func runCommand(t *testing.T, cmd *cobra.Command, args ...string) error {
+	t.Helper()
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		_ = flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+	err := cmd.ParseFlags(args)
+	if err != nil {
+		return err
+	}
+	err = cmd.ValidateArgs(cmd.Flags().Args())
+	if err != nil {
+		return err
+	}
+	return cmd.RunE(cmd, cmd.Flags().Args())
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var credentialProcessWriteConfig bool
+
+var credentialProcessCmd = &cobra.Command{
+	Use:               "credential-process <config> <profile>",
+	Short:             "Prints credentials in the format expected by credential_process",
+	Long:              `Prints credentials for the last used account and role of a profile in the JSON format expected by the credential_process setting of the AWS SDKs, without writing them to ~/.aws/credentials.`,
+	Example:           "awsx credential-process default my-profile --write-config",
+	DisableAutoGenTag: true,
+	Args:              cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, profile, err := readConfigAndProfile(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		if credentialProcessWriteConfig {
+			executable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			return internal.WriteCredentialProcessToAwsConfig(profile.Name, internal.CredentialProcessCommand(executable, config.Name, profile.Name))
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		output, err := internal.CredentialProcess(config, profile, oidcApi, ssoApi)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(output))
+		return nil
+	},
+}
+
+func init() {
+	credentialProcessCmd.Flags().BoolVarP(&credentialProcessWriteConfig, "write-config", "w", false, "Adds the matching credential_process line to the profile in ~/.aws/config instead of printing credentials")
+	rootCmd.AddCommand(credentialProcessCmd)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var daemonLeadTime time.Duration
+var daemonInterval time.Duration
+var daemonInstallUnit bool
+
+var daemonCmd = &cobra.Command{
+	Use:               "daemon",
+	Short:             "Refreshes previously used credentials before they expire",
+	Long:              `Runs in the foreground and refreshes the credentials of every previously used profile shortly before they expire. A browser login is only required when the SSO access token itself can no longer be renewed.`,
+	Example:           "awsx daemon --lead-time 10m",
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if daemonInterval <= 0 {
+			return fmt.Errorf("--interval must be positive, got %s", daemonInterval)
+		}
+		if daemonLeadTime < 0 {
+			return fmt.Errorf("--lead-time must not be negative, got %s", daemonLeadTime)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		daemon := &internal.Daemon{LeadTime: daemonLeadTime, Interval: daemonInterval}
+		return daemon.Run(ctx)
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:               "stop",
+	Short:             "Stops the running awsx daemon",
+	Long:              `Stops the running awsx daemon`,
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pid, err := internal.StopDaemon()
+		if err != nil {
+			return err
+		}
+		log.Printf("Stopped the awsx daemon with pid %d\n", pid)
+		return nil
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:               "status",
+	Short:             "Prints whether the awsx daemon is running",
+	Long:              `Prints whether the awsx daemon is running`,
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pid, err := internal.RunningDaemonPid()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("The awsx daemon is running with pid %d\n", pid)
+		return nil
+	},
+}
+
+var daemonSystemdUnitCmd = &cobra.Command{
+	Use:               "systemd-unit",
+	Short:             "Prints a systemd user unit for the awsx daemon",
+	Long:              `Prints a systemd user unit that runs the awsx daemon, or installs it with --install`,
+	Example:           "awsx daemon systemd-unit --install && systemctl --user enable --now awsx-daemon",
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if daemonLeadTime < 0 {
+			return fmt.Errorf("--lead-time must not be negative, got %s", daemonLeadTime)
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		unit := internal.DaemonSystemdUnit(executable, daemonLeadTime)
+		if !daemonInstallUnit {
+			fmt.Print(unit)
+			return nil
+		}
+
+		unitPath := internal.DaemonSystemdUnitPath()
+		err = os.MkdirAll(path.Dir(unitPath), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(unitPath, []byte(unit), 0644)
+		if err != nil {
+			return err
+		}
+		log.Printf("Installed %s\n", unitPath)
+		return nil
+	},
+}
+
+func init() {
+	daemonCmd.Flags().DurationVar(&daemonLeadTime, "lead-time", 10*time.Minute, "How long before their expiry credentials are refreshed")
+	daemonSystemdUnitCmd.Flags().DurationVar(&daemonLeadTime, "lead-time", 10*time.Minute, "How long before their expiry credentials are refreshed")
+	daemonCmd.Flags().DurationVar(&daemonInterval, "interval", time.Minute, "How often the expiry of the credentials is checked")
+	daemonSystemdUnitCmd.Flags().BoolVar(&daemonInstallUnit, "install", false, "Writes the unit to ~/.config/systemd/user instead of printing it")
+	daemonCmd.AddCommand(daemonStopCmd, daemonStatusCmd, daemonSystemdUnitCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var envShell string
+var envUnset bool
+
+var envCmd = &cobra.Command{
+	Use:               "env <config> <profile>",
+	Short:             "Prints credentials as shell export statements",
+	Long:              `Prints the credentials of the last used account and role of a profile as statements for the given shell, to be evaluated or written to a .env file.`,
+	Example:           "eval \"$(awsx env default my-profile --shell bash)\"",
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if envUnset {
+			output, err := internal.FormatUnsetEnvironment(envShell, internal.CredentialsEnvironmentVariableNames)
+			if err != nil {
+				return err
+			}
+			fmt.Print(output)
+			return nil
+		}
+
+		if len(args) != 2 {
+			return errors.New("usage: awsx env <config> <profile>")
+		}
+
+		config, profile, err := readConfigAndProfile(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		roleCredentials, _, err := internal.RetrieveCredentials(config, profile, oidcApi, ssoApi)
+		if err != nil {
+			return err
+		}
+
+		output, err := internal.FormatEnvironment(envShell, internal.CredentialsEnvironment(roleCredentials, profile.Region))
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	envCmd.Flags().StringVarP(&envShell, "shell", "s", "bash", "Output format, one of: "+strings.Join(internal.Shells, ", "))
+	envCmd.Flags().BoolVar(&envUnset, "unset", false, "Prints the statements that clear the credentials again")
+	rootCmd.AddCommand(envCmd)
+}
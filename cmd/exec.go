@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:               "exec <config> <profile> -- <command> [args...]",
+	Short:             "Runs a command with credentials injected into its environment",
+	Long:              `Runs a command with the credentials of the last used account and role of a profile set in its environment, without writing them to ~/.aws/credentials.`,
+	Example:           "awsx exec default my-profile -- terraform plan",
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash != 2 || len(args) < 3 {
+			return errors.New("usage: awsx exec <config> <profile> -- <command> [args...]")
+		}
+
+		config, profile, err := readConfigAndProfile(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		roleCredentials, _, err := internal.RetrieveCredentials(config, profile, oidcApi, ssoApi)
+		if err != nil {
+			return err
+		}
+
+		exitCode, err := internal.RunWithEnvironment(args[dash:], internal.CredentialsEnvironment(roleCredentials, profile.Region))
+		if err != nil {
+			return err
+		}
+
+		os.Exit(exitCode)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var imdsAddress string
+
+var imdsCmd = &cobra.Command{
+	Use:               "imds <config> <profile>",
+	Short:             "Emulates the EC2 instance metadata service for legacy tooling",
+	Long:              `Serves the credentials of the last used account and role of a profile through an emulation of the IMDSv2 token handshake and security credentials paths of the EC2 instance metadata service.`,
+	Example:           "awsx imds default my-profile --address 127.0.0.1:1338",
+	DisableAutoGenTag: true,
+	Args:              cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, profile, err := readConfigAndProfile(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		cache := internal.NewCredentialsCache(config, profile, oidcApi, ssoApi)
+
+		// Retrieve credentials up front so a required login happens before clients connect
+		if _, err = cache.Get(); err != nil {
+			return err
+		}
+
+		return internal.Serve(imdsAddress, internal.ImdsHandler(cache, profile.Name, profile.Region), func(addr net.Addr) {
+			log.Printf("Serving instance metadata for profile %s in %s config on %s", profile.Name, config.Name, addr.String())
+			fmt.Printf("AWS_EC2_METADATA_SERVICE_ENDPOINT=http://%s/\n", addr.String())
+		})
+	},
+}
+
+func init() {
+	imdsCmd.Flags().StringVarP(&imdsAddress, "address", "a", "127.0.0.1:1338", "Address to listen on")
+	rootCmd.AddCommand(imdsCmd)
+}
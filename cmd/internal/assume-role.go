@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	stsTypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultSessionNameTemplate = "awsx-{{.Profile}}"
+
+var sessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// AssumeRole is one hop from the credentials of the previous hop, or the SSO role, into an IAM role.
+type AssumeRole struct {
+	RoleArn        string            `yaml:"role_arn"`
+	ExternalId     string            `yaml:"external_id,omitempty"`
+	SessionName    string            `yaml:"session_name,omitempty"`
+	Duration       time.Duration     `yaml:"duration,omitempty"`
+	SessionTags    map[string]string `yaml:"session_tags,omitempty"`
+	SourceIdentity string            `yaml:"source_identity,omitempty"`
+}
+
+// AssumeRoleChain is a list of roles assumed one after another. In the configuration
+// a single role can also be written as a mapping instead of a list.
+type AssumeRoleChain []*AssumeRole
+
+func (c *AssumeRoleChain) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		assumeRole := &AssumeRole{}
+		if err := value.Decode(assumeRole); err != nil {
+			return err
+		}
+		*c = AssumeRoleChain{assumeRole}
+		return nil
+	}
+
+	var chain []*AssumeRole
+	if err := value.Decode(&chain); err != nil {
+		return err
+	}
+	*c = chain
+	return nil
+}
+
+// AssumeRoleTemplateData is available in the session_name template of an assume_role hop.
+type AssumeRoleTemplateData struct {
+	Config      string
+	Profile     string
+	AccountId   string
+	AccountName string
+	Role        string
+}
+
+// assumeRoleChain assumes the roles of the profile's assume_role chain starting from the SSO role credentials
+// and returns the credentials of the last role. Without a chain it returns the SSO role credentials.
+func assumeRoleChain(config *Config, profile *Profile, usage *UsageInformation, credentials *ssoTypes.RoleCredentials) (*ssoTypes.RoleCredentials, error) {
+	if len(profile.AssumeRole) == 0 {
+		return credentials, nil
+	}
+
+	data := AssumeRoleTemplateData{
+		Config:      config.Name,
+		Profile:     profile.Name,
+		AccountId:   usage.AccountId,
+		AccountName: usage.AccountName,
+		Role:        usage.Role,
+	}
+
+	for _, assumeRole := range profile.AssumeRole {
+		stsClient, err := newStsClient(config, profile.Region, credentials)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials, err = assumeRole.assume(stsClient, data)
+		if err != nil {
+			return nil, fmt.Errorf("assuming role %s: %w", assumeRole.RoleArn, err)
+		}
+		log.Printf("Assumed role: %s", assumeRole.RoleArn)
+	}
+
+	return credentials, nil
+}
+
+func newStsClient(config *Config, region string, credentials *ssoTypes.RoleCredentials) (*sts.Client, error) {
+	cfg, err := loadAwsConfig(config, region)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Credentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     *credentials.AccessKeyId,
+			SecretAccessKey: *credentials.SecretAccessKey,
+			SessionToken:    *credentials.SessionToken,
+			Source:          "awsx",
+			CanExpire:       true,
+			Expires:         time.UnixMilli(credentials.Expiration),
+		}, nil
+	})
+
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if config.StsEndpoint != "" {
+			o.BaseEndpoint = aws.String(config.StsEndpoint)
+		}
+	}), nil
+}
+
+func (a *AssumeRole) assume(stsClient *sts.Client, data AssumeRoleTemplateData) (*ssoTypes.RoleCredentials, error) {
+	if a.RoleArn == "" {
+		return nil, errors.New("role_arn is missing")
+	}
+
+	sessionName, err := a.sessionName(data)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(a.RoleArn),
+		RoleSessionName: aws.String(sessionName),
+	}
+	if a.ExternalId != "" {
+		input.ExternalId = aws.String(a.ExternalId)
+	}
+	if a.Duration > 0 {
+		input.DurationSeconds = aws.Int32(int32(a.Duration.Seconds()))
+	}
+	if a.SourceIdentity != "" {
+		input.SourceIdentity = aws.String(a.SourceIdentity)
+	}
+	for key, value := range a.SessionTags {
+		input.Tags = append(input.Tags, stsTypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	output, err := stsClient.AssumeRole(context.Background(), input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssoTypes.RoleCredentials{
+		AccessKeyId:     output.Credentials.AccessKeyId,
+		SecretAccessKey: output.Credentials.SecretAccessKey,
+		SessionToken:    output.Credentials.SessionToken,
+		Expiration:      output.Credentials.Expiration.UnixMilli(),
+	}, nil
+}
+
+func (a *AssumeRole) sessionName(data AssumeRoleTemplateData) (string, error) {
+	sessionNameTemplate := a.SessionName
+	if sessionNameTemplate == "" {
+		sessionNameTemplate = defaultSessionNameTemplate
+	}
+
+	parsed, err := template.New("session_name").Parse(sessionNameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid session_name template: %w", err)
+	}
+
+	var sessionName strings.Builder
+	err = parsed.Execute(&sessionName, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid session_name template: %w", err)
+	}
+
+	if !sessionNamePattern.MatchString(sessionName.String()) {
+		return "", fmt.Errorf("session name %q must be 2 to 64 characters of letters, digits and +=,.@_-", sessionName.String())
+	}
+	return sessionName.String(), nil
+}
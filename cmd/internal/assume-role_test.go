@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAssumeRoleChainUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    AssumeRoleChain
+		wantErr bool
+	}{
+		{
+			name: "single role as mapping",
+			yaml: "role_arn: arn:aws:iam::111111111111:role/Admin\nexternal_id: ext\nduration: 15m\n",
+			want: AssumeRoleChain{{RoleArn: "arn:aws:iam::111111111111:role/Admin", ExternalId: "ext", Duration: 15 * time.Minute}},
+		},
+		{
+			name: "chain as list",
+			yaml: "- role_arn: arn:aws:iam::111111111111:role/Hop\n- role_arn: arn:aws:iam::222222222222:role/Target\n  session_tags:\n    team: platform\n",
+			want: AssumeRoleChain{
+				{RoleArn: "arn:aws:iam::111111111111:role/Hop"},
+				{RoleArn: "arn:aws:iam::222222222222:role/Target", SessionTags: map[string]string{"team": "platform"}},
+			},
+		},
+		{
+			name:    "scalar",
+			yaml:    "arn:aws:iam::111111111111:role/Admin\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chain AssumeRoleChain
+			err := yaml.Unmarshal([]byte(tt.yaml), &chain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(chain, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", chain, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssumeRoleSessionName(t *testing.T) {
+	data := AssumeRoleTemplateData{Config: "work", Profile: "dev", AccountId: "111111111111", AccountName: "dev account", Role: "Admin"}
+
+	tests := []struct {
+		name        string
+		sessionName string
+		want        string
+		wantErr     bool
+	}{
+		{name: "default", want: "awsx-dev"},
+		{name: "template", sessionName: "{{.Config}}-{{.Role}}@{{.AccountId}}", want: "work-Admin@111111111111"},
+		{name: "literal", sessionName: "ci.pipeline", want: "ci.pipeline"},
+		{name: "invalid character", sessionName: "{{.AccountName}}", wantErr: true},
+		{name: "too short", sessionName: "a", wantErr: true},
+		{name: "too long", sessionName: strings.Repeat("a", 65), wantErr: true},
+		{name: "unknown field", sessionName: "{{.Unknown}}", wantErr: true},
+		{name: "invalid template", sessionName: "{{.Profile", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&AssumeRole{SessionName: tt.sessionName}).sessionName(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sessionName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sessionName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+var signingAccessKeyIdPattern = regexp.MustCompile(`Credential=([^/]+)/`)
+
+// stsRequest is an AssumeRole request received by newTestStsServer.
+type stsRequest struct {
+	accessKeyId string
+	form        map[string]string
+}
+
+// newTestStsServer answers AssumeRole requests with credentials named after the role and records the requests.
+func newTestStsServer(t *testing.T) (*httptest.Server, *[]stsRequest) {
+	t.Helper()
+	var mutex sync.Mutex
+	var requests []stsRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("Action") != "AssumeRole" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		// The access key ID that signed the request is part of the credential scope
+		accessKeyId := ""
+		if match := signingAccessKeyIdPattern.FindStringSubmatch(r.Header.Get("Authorization")); match != nil {
+			accessKeyId = match[1]
+		}
+		form := make(map[string]string)
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		mutex.Lock()
+		requests = append(requests, stsRequest{accessKeyId: accessKeyId, form: form})
+		mutex.Unlock()
+
+		role := r.PostForm.Get("RoleArn")[strings.LastIndex(r.PostForm.Get("RoleArn"), "/")+1:]
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIA%[1]s</AccessKeyId>
+      <SecretAccessKey>secret-%[1]s</SecretAccessKey>
+      <SessionToken>token-%[1]s</SessionToken>
+      <Expiration>2030-01-02T03:04:05Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>%[2]s/%[3]s</Arn>
+      <AssumedRoleId>AROA:%[3]s</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>request</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`, strings.ToUpper(role), r.PostForm.Get("RoleArn"), r.PostForm.Get("RoleSessionName"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestAssumeRoleChain(t *testing.T) {
+	useTempHome(t)
+	server, requests := newTestStsServer(t)
+	config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", StsEndpoint: server.URL}
+	profile := &Profile{Name: "dev", Region: "eu-west-1", AssumeRole: AssumeRoleChain{
+		{RoleArn: "arn:aws:iam::111111111111:role/Hop", Duration: 15 * time.Minute},
+		{RoleArn: "arn:aws:iam::222222222222:role/Target", ExternalId: "ext", SessionName: "{{.Config}}-{{.Role}}", SessionTags: map[string]string{"team": "platform"}},
+	}}
+	usage := &UsageInformation{AccountId: "000000000000", AccountName: "sso", Role: "Admin", Profile: "dev"}
+	ssoCredentials := &ssoTypes.RoleCredentials{
+		AccessKeyId:     aws.String("ASIASSO"),
+		SecretAccessKey: aws.String("secret-sso"),
+		SessionToken:    aws.String("token-sso"),
+		Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+	}
+
+	credentials, err := assumeRoleChain(config, profile, usage, ssoCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(credentials.AccessKeyId) != "ASIATARGET" || credentials.Expiration != time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli() {
+		t.Fatalf("credentials = %+v, want those of the last role", credentials)
+	}
+
+	want := []stsRequest{
+		{accessKeyId: "ASIASSO", form: map[string]string{
+			"Action": "AssumeRole", "Version": "2011-06-15", "RoleArn": "arn:aws:iam::111111111111:role/Hop",
+			"RoleSessionName": "awsx-dev", "DurationSeconds": "900",
+		}},
+		{accessKeyId: "ASIAHOP", form: map[string]string{
+			"Action": "AssumeRole", "Version": "2011-06-15", "RoleArn": "arn:aws:iam::222222222222:role/Target",
+			"RoleSessionName": "work-Admin", "ExternalId": "ext", "Tags.member.1.Key": "team", "Tags.member.1.Value": "platform",
+		}},
+	}
+	if !reflect.DeepEqual(*requests, want) {
+		t.Fatalf("requests = %+v, want %+v", *requests, want)
+	}
+}
+
+func TestAssumeRoleChainWithoutRoles(t *testing.T) {
+	ssoCredentials := &ssoTypes.RoleCredentials{AccessKeyId: aws.String("ASIASSO")}
+	credentials, err := assumeRoleChain(&Config{Name: "work"}, &Profile{Name: "dev"}, &UsageInformation{}, ssoCredentials)
+	if err != nil || credentials != ssoCredentials {
+		t.Fatalf("assumeRoleChain() = %+v, %v, want the SSO role credentials", credentials, err)
+	}
+}
+
+func TestAssumeRoleChainStopsAtFailingHop(t *testing.T) {
+	useTempHome(t)
+	server, requests := newTestStsServer(t)
+	config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", StsEndpoint: server.URL}
+	profile := &Profile{Name: "dev", Region: "eu-west-1", AssumeRole: AssumeRoleChain{
+		{RoleArn: "arn:aws:iam::111111111111:role/Hop", SessionName: "x"},
+		{RoleArn: "arn:aws:iam::222222222222:role/Target"},
+	}}
+	ssoCredentials := &ssoTypes.RoleCredentials{AccessKeyId: aws.String("ASIASSO"), SecretAccessKey: aws.String("secret"), SessionToken: aws.String("token")}
+
+	_, err := assumeRoleChain(config, profile, &UsageInformation{}, ssoCredentials)
+	if err == nil || !strings.Contains(err.Error(), "arn:aws:iam::111111111111:role/Hop") {
+		t.Fatalf("assumeRoleChain() error = %v, want the failing role in the error", err)
+	}
+	if len(*requests) != 0 {
+		t.Fatalf("%d requests to STS, want none after the session name was rejected", len(*requests))
+	}
+}
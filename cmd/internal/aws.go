@@ -5,42 +5,97 @@ import (
 	"errors"
 	"fmt"
 
-	ssoConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
-	oidcTypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
-	"github.com/aws/smithy-go"
 
 	"log"
+	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 )
 
 const grantType = "urn:ietf:params:oauth:grant-type:device_code"
+const refreshTokenGrantType = "refresh_token"
+const accountAccessScope = "sso:account:access"
 const clientType = "public"
 const clientName = "awsx"
 
+const defaultPollingInterval = 5 * time.Second
+const slowDownIncrement = 5 * time.Second
+const defaultDeviceCodeExpiry = 10 * time.Minute
+
+var ErrLoginCancelled = errors.New("login cancelled")
+var ErrDeviceCodeExpired = errors.New("the device authorization expired before it was confirmed")
+var ErrAuthorizationDenied = errors.New("the authorization request was denied")
+
 func (ati ClientInformation) IsExpired() (bool, bool) {
 	return ati.AccessTokenExpiresAt.Before(time.Now()), ati.ClientSecretExpiresAt.Before(time.Now())
 }
 
-func ProcessClientInformation(configName string, startUrl string, oidcClient *ssooidc.Client) (*ClientInformation, error) {
-	clientInformation, err := GetClientInformationForConfig(configName)
+func (ati ClientInformation) loginFlow() string {
+	if ati.LoginFlow == "" {
+		return LoginFlowDeviceCode
+	}
+	return ati.LoginFlow
+}
+
+// loginMutexes serializes the renewal of the access token per config, so that concurrent refreshes wait for one
+// login instead of each starting its own.
+var loginMutexes sync.Map
+
+func lockLogin(configName string) func() {
+	mutex, _ := loginMutexes.LoadOrStore(configName, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
+
+func ProcessClientInformation(config *Config, oidcClient OidcClient) (*ClientInformation, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockLogin(config.Name)
+	defer unlock()
+
+	clientInformation, err := LoadClientInformation(config)
+	if errors.Is(err, ErrCacheKey) || errors.Is(err, ErrPlaintextTokenCache) {
+		return nil, err
+	}
 	if err != nil {
-		return Register(configName, startUrl, oidcClient)
+		return Register(config, oidcClient)
 	}
 
 	accessTokenExpired, clientSecretExpired := clientInformation.IsExpired()
+	if clientInformation.AccessToken != "" && !accessTokenExpired {
+		// A valid access token is used as is, also when it was issued to another client like the AWS CLI,
+		// and the client registration only matters once the token has to be renewed
+		return clientInformation, nil
+	}
 	if clientSecretExpired {
-		return Register(configName, startUrl, oidcClient)
+		return Register(config, oidcClient)
+	}
+	if clientInformation.loginFlow() != config.GetLoginFlow() {
+		// The client registration of one login flow cannot be used for the other one
+		log.Println("Login flow changed. Registering a new client.")
+		return Register(config, oidcClient)
 	}
 	if accessTokenExpired {
+		if clientInformation.RefreshToken == "" {
+			// Clients registered before refresh tokens were requested never receive one, so register anew
+			log.Println("AccessToken expired. Registering a new client to retrieve a new AccessToken.")
+			return Register(config, oidcClient)
+		}
+
 		log.Println("AccessToken expired. Start retrieving a new AccessToken.")
-		clientInformation, err = HandleOutdatedAccessToken(configName, startUrl, clientInformation, oidcClient)
+		clientInformation, err = RenewAccessToken(config, clientInformation, oidcClient)
 		if err != nil {
 			return nil, err
 		}
@@ -48,14 +103,70 @@ func ProcessClientInformation(configName string, startUrl string, oidcClient *ss
 	return clientInformation, nil
 }
 
-func Register(configName string, startUrl string, oidcClient *ssooidc.Client) (*ClientInformation, error) {
-	clientInformation, err := registerClient(oidcClient, startUrl)
+// RenewAccessToken silently exchanges the refresh token for a new access token and only falls back
+// to the login in the browser when that is not possible.
+func RenewAccessToken(config *Config, clientInformation *ClientInformation, oidcClient OidcClient) (*ClientInformation, error) {
+	if clientInformation.RefreshToken != "" {
+		refreshedInfo, err := refreshAccessToken(oidcClient, clientInformation)
+		if err == nil {
+			err = StoreClientInformation(config, refreshedInfo)
+			if err != nil {
+				return nil, err
+			}
+			return refreshedInfo, nil
+		}
+		log.Printf("Failed to refresh the AccessToken, falling back to login in the browser: %v\n", err)
+		clientInformation.RefreshToken = ""
+	}
+
+	return HandleOutdatedAccessToken(config, clientInformation, oidcClient)
+}
+
+func refreshAccessToken(client OidcClient, info *ClientInformation) (*ClientInformation, error) {
+	gtp := refreshTokenGrantType
+	cto, err := client.CreateToken(context.Background(), &ssooidc.CreateTokenInput{
+		ClientId:     &info.ClientId,
+		ClientSecret: &info.ClientSecret,
+		RefreshToken: &info.RefreshToken,
+		GrantType:    &gtp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshedInfo := *info
+	refreshedInfo.applyCreateTokenOutput(cto)
+	return &refreshedInfo, nil
+}
+
+func (ati *ClientInformation) applyCreateTokenOutput(cto *ssooidc.CreateTokenOutput) {
+	ati.AccessToken = *cto.AccessToken
+	if cto.RefreshToken != nil {
+		ati.RefreshToken = *cto.RefreshToken
+	}
+	// Use server-provided expiry when available; fall back to 8h. Apply small safety skew.
+	expiryDuration := time.Hour * 8
+	if cto.ExpiresIn > 0 {
+		expiryDuration = time.Duration(cto.ExpiresIn) * time.Second
+	}
+	if expiryDuration > 5*time.Minute {
+		expiryDuration -= 5 * time.Minute
+	}
+	ati.AccessTokenExpiresAt = time.Now().Add(expiryDuration)
+}
+
+func Register(config *Config, oidcClient OidcClient) (*ClientInformation, error) {
+	clientInformation, err := registerClient(oidcClient, config)
 	if err != nil {
 		return nil, err
 	}
 
-	clientInformation = retrieveToken(oidcClient, clientInformation)
-	err = SetClientInformationForConfig(configName, clientInformation)
+	clientInformation, err = login(config, clientInformation, oidcClient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = StoreClientInformation(config, clientInformation)
 	if err != nil {
 		return nil, err
 	}
@@ -63,18 +174,13 @@ func Register(configName string, startUrl string, oidcClient *ssooidc.Client) (*
 	return clientInformation, nil
 }
 
-func HandleOutdatedAccessToken(configName string, startUrl string, clientInformation *ClientInformation, oidcClient *ssooidc.Client) (*ClientInformation, error) {
-	registerClientOutput := ssooidc.RegisterClientOutput{ClientId: &clientInformation.ClientId, ClientSecret: &clientInformation.ClientSecret}
-	sda, err := startDeviceAuthorization(oidcClient, &registerClientOutput, startUrl)
+func HandleOutdatedAccessToken(config *Config, clientInformation *ClientInformation, oidcClient OidcClient) (*ClientInformation, error) {
+	clientInfoPointer, err := login(config, clientInformation, oidcClient)
 	if err != nil {
 		return nil, err
 	}
 
-	clientInformation.DeviceCode = *sda.DeviceCode
-
-	var clientInfoPointer *ClientInformation
-	clientInfoPointer = retrieveToken(oidcClient, clientInformation)
-	err = SetClientInformationForConfig(configName, clientInfoPointer)
+	err = StoreClientInformation(config, clientInfoPointer)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +188,27 @@ func HandleOutdatedAccessToken(configName string, startUrl string, clientInforma
 	return clientInfoPointer, nil
 }
 
+// login retrieves a new access token through the browser using the login flow of the config.
+// It can be cancelled with Ctrl-C.
+func login(config *Config, clientInformation *ClientInformation, oidcClient OidcClient) (*ClientInformation, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if config.GetLoginFlow() == LoginFlowAuthorizationCode {
+		return retrieveTokenWithAuthorizationCode(ctx, oidcClient, clientInformation)
+	}
+
+	sdao, err := startDeviceAuthorization(ctx, oidcClient, clientInformation, config.GetStartUrl())
+	if err != nil {
+		return nil, err
+	}
+
+	clientInformation.DeviceCode = *sdao.DeviceCode
+	clientInformation.VerificationUriComplete = *sdao.VerificationUriComplete
+
+	return retrieveToken(ctx, oidcClient, clientInformation, sdao)
+}
+
 func generateCreateTokenInput(clientInformation *ClientInformation) ssooidc.CreateTokenInput {
 	gtp := grantType
 	return ssooidc.CreateTokenInput{
@@ -92,33 +219,38 @@ func generateCreateTokenInput(clientInformation *ClientInformation) ssooidc.Crea
 	}
 }
 
-func registerClient(oidc *ssooidc.Client, startUrl string) (*ClientInformation, error) {
+func registerClient(oidc OidcClient, config *Config) (*ClientInformation, error) {
 	cn := clientName
 	ct := clientType
+	startUrl := config.GetStartUrl()
 
-	rci := ssooidc.RegisterClientInput{ClientName: &cn, ClientType: &ct}
-	rco, err := oidc.RegisterClient(context.Background(), &rci)
-	if err != nil {
-		return nil, err
+	rci := ssooidc.RegisterClientInput{
+		ClientName: &cn,
+		ClientType: &ct,
+		GrantTypes: []string{grantType, refreshTokenGrantType},
+		Scopes:     []string{accountAccessScope},
 	}
-
-	sdao, err := startDeviceAuthorization(oidc, rco, startUrl)
+	if config.GetLoginFlow() == LoginFlowAuthorizationCode {
+		rci.GrantTypes = []string{authorizationCodeGrantType, refreshTokenGrantType}
+		rci.RedirectUris = []string{redirectUriWithoutPort()}
+		rci.IssuerUrl = &startUrl
+	}
+	rco, err := oidc.RegisterClient(context.Background(), &rci)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ClientInformation{
-		ClientId:                *rco.ClientId,
-		ClientSecret:            *rco.ClientSecret,
-		ClientSecretExpiresAt:   time.Unix(rco.ClientSecretExpiresAt, 0),
-		DeviceCode:              *sdao.DeviceCode,
-		VerificationUriComplete: *sdao.VerificationUriComplete,
-		StartUrl:                startUrl,
+		ClientId:              *rco.ClientId,
+		ClientSecret:          *rco.ClientSecret,
+		ClientSecretExpiresAt: time.Unix(rco.ClientSecretExpiresAt, 0),
+		StartUrl:              startUrl,
+		LoginFlow:             config.GetLoginFlow(),
 	}, nil
 }
 
-func startDeviceAuthorization(ssoClient *ssooidc.Client, rco *ssooidc.RegisterClientOutput, startUrl string) (*ssooidc.StartDeviceAuthorizationOutput, error) {
-	sdao, err := ssoClient.StartDeviceAuthorization(context.Background(), &ssooidc.StartDeviceAuthorizationInput{ClientId: rco.ClientId, ClientSecret: rco.ClientSecret, StartUrl: &startUrl})
+func startDeviceAuthorization(ctx context.Context, ssoClient OidcClient, clientInformation *ClientInformation, startUrl string) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	sdao, err := ssoClient.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{ClientId: &clientInformation.ClientId, ClientSecret: &clientInformation.ClientSecret, StartUrl: &startUrl})
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +260,11 @@ func startDeviceAuthorization(ssoClient *ssooidc.Client, rco *ssooidc.RegisterCl
 	return sdao, nil
 }
 
-func openUrlInBrowser(url string) {
+// openUrlInBrowser opens a URL of the login for the user. Tests replace it to keep the browser closed or to
+// let the fake answer the authorization.
+var openUrlInBrowser = openUrlInSystemBrowser
+
+func openUrlInSystemBrowser(url string) {
 	var err error
 
 	switch runtime.GOOS {
@@ -142,59 +278,113 @@ func openUrlInBrowser(url string) {
 		err = fmt.Errorf("could not open %s - unsupported platform. Please open the URL manually", url)
 	}
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Failed to open the browser, please open the URL manually: %v\n", err)
 	}
 }
 
-func retrieveToken(client *ssooidc.Client, info *ClientInformation) *ClientInformation {
+// retrieveToken polls for the access token until the device authorization is confirmed, denied or expired,
+// following the polling interval of the authorization server.
+func retrieveToken(ctx context.Context, client OidcClient, info *ClientInformation, sdao *ssooidc.StartDeviceAuthorizationOutput) (*ClientInformation, error) {
 	input := generateCreateTokenInput(info)
-	var authorizationPendingException *oidcTypes.AuthorizationPendingException
+
+	interval := defaultPollingInterval
+	if sdao.Interval > 0 {
+		interval = time.Duration(sdao.Interval) * time.Second
+	}
+	expiresIn := defaultDeviceCodeExpiry
+	if sdao.ExpiresIn > 0 {
+		expiresIn = time.Duration(sdao.ExpiresIn) * time.Second
+	}
+	deadline := time.Now().Add(expiresIn)
+
 	for {
-		cto, err := client.CreateToken(context.Background(), &input)
-		if err != nil {
-			if errors.As(err, &authorizationPendingException) {
-				log.Println("Still waiting for authorization...")
-				time.Sleep(3 * time.Second)
-				continue
-			} else if smithyErr, ok := err.(*smithy.GenericAPIError); ok {
-				log.Printf("API error: %v\n", smithyErr.Code)
-			} else {
-				log.Fatal(err)
-			}
-		} else {
-			info.AccessToken = *cto.AccessToken
-			// Use server-provided expiry when available; fall back to 8h. Apply small safety skew.
-			expiryDuration := time.Hour * 8
-			if cto.ExpiresIn > 0 {
-				expiryDuration = time.Duration(cto.ExpiresIn) * time.Second
-			}
-			if expiryDuration > 5*time.Minute {
-				expiryDuration -= 5 * time.Minute
-			}
-			info.AccessTokenExpiresAt = time.Now().Add(expiryDuration)
-			return info
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrLoginCancelled, ctx.Err())
+		case <-time.After(interval):
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrDeviceCodeExpired
+		}
+
+		cto, err := client.CreateToken(ctx, &input)
+		if err == nil {
+			info.applyCreateTokenOutput(cto)
+			return info, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLoginCancelled, ctx.Err())
+		}
+
+		switch apiErrorCode(err) {
+		case "":
+			return nil, err
+		case "AuthorizationPendingException":
+			log.Println("Still waiting for authorization...")
+		case "SlowDownException":
+			interval += slowDownIncrement
+		case "ExpiredTokenException":
+			return nil, ErrDeviceCodeExpired
+		case "AccessDeniedException":
+			return nil, ErrAuthorizationDenied
+		default:
+			return nil, fmt.Errorf("failed to retrieve the access token: %w", err)
 		}
 	}
 }
 
-func InitClients(config *Config) (*ssooidc.Client, *sso.Client) {
-	cfg, _ := ssoConfig.LoadDefaultConfig(context.Background(), ssoConfig.WithRegion(config.SsoRegion))
-	oidcClient := ssooidc.NewFromConfig(cfg)
-	ssoClient := sso.NewFromConfig(cfg)
+func InitClients(config *Config) (OidcClient, SsoClient, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := loadAwsConfig(config, config.SsoRegion)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return oidcClient, ssoClient
+	oidcClient := ssooidc.NewFromConfig(cfg, func(o *ssooidc.Options) {
+		if config.OidcEndpoint != "" {
+			o.BaseEndpoint = aws.String(config.OidcEndpoint)
+		}
+	})
+	ssoClient := sso.NewFromConfig(cfg, func(o *sso.Options) {
+		if config.SsoEndpoint != "" {
+			o.BaseEndpoint = aws.String(config.SsoEndpoint)
+		}
+	})
+
+	return oidcClient, ssoClient, nil
 }
 
-func RetrieveRoleInfo(accountId *string, clientInformation *ClientInformation, ssoClient *sso.Client, selector Prompt) ssoTypes.RoleInfo {
-	lari := &sso.ListAccountRolesInput{AccountId: accountId, AccessToken: &clientInformation.AccessToken}
-	roles, _ := ssoClient.ListAccountRoles(context.Background(), lari)
+func RetrieveRoleInfo(accountId *string, catalog *AccountCatalog, selector Prompt, rolePattern string) (ssoTypes.RoleInfo, error) {
+	roleList, err := catalog.Roles(*accountId)
+	if err != nil {
+		return ssoTypes.RoleInfo{}, err
+	}
 
-	if len(roles.RoleList) == 1 {
-		log.Printf("Only one role available. Selected role: %s\n", *roles.RoleList[0].RoleName)
-		return roles.RoleList[0]
+	if len(roleList) == 0 {
+		return ssoTypes.RoleInfo{}, fmt.Errorf("no roles available in account %s", *accountId)
 	}
 
-	sortedRoles := sortRoles(roles.RoleList)
+	if rolePattern != "" {
+		roleInfo, err := matchRole(roleList, rolePattern)
+		if err != nil {
+			return ssoTypes.RoleInfo{}, err
+		}
+		log.Printf("Selected role: %s\n", *roleInfo.RoleName)
+		return roleInfo, nil
+	}
+
+	if len(roleList) == 1 {
+		log.Printf("Only one role available. Selected role: %s\n", *roleList[0].RoleName)
+		return roleList[0], nil
+	}
+
+	sortedRoles := sortRoles(roleList)
 	var rolesToSelect []string
 	linePrefix := "#"
 
@@ -203,32 +393,79 @@ func RetrieveRoleInfo(accountId *string, clientInformation *ClientInformation, s
 	}
 
 	label := "Select your role - Hint: fuzzy search supported. To choose one role directly just enter #{Int}"
-	indexChoice, _, _ := selector.Select(label, rolesToSelect, fuzzySearchWithPrefixAnchor(rolesToSelect, linePrefix))
+	indexChoice, _, err := selector.Select(label, rolesToSelect, fuzzySearchWithPrefixAnchor(rolesToSelect, linePrefix))
+	if err != nil {
+		return ssoTypes.RoleInfo{}, err
+	}
 	roleInfo := sortedRoles[indexChoice]
-	return roleInfo
+	return roleInfo, nil
 }
 
-func RetrieveAccountInfo(clientInformation *ClientInformation, ssoClient *sso.Client, selector Prompt) ssoTypes.AccountInfo {
-	var maxSize int32 = 1000 // default is 20
-	lai := sso.ListAccountsInput{AccessToken: &clientInformation.AccessToken, MaxResults: &maxSize}
-	accounts, _ := ssoClient.ListAccounts(context.Background(), &lai)
+func RetrieveAccountInfo(catalog *AccountCatalog, selector Prompt, accountPattern string) (ssoTypes.AccountInfo, error) {
+	accountList, err := catalog.Accounts()
+	if err != nil {
+		return ssoTypes.AccountInfo{}, err
+	}
 
-	sortedAccounts := sortAccounts(accounts.AccountList)
+	if len(accountList) == 0 {
+		return ssoTypes.AccountInfo{}, errors.New("no accounts available")
+	}
 
-	var accountsToSelect []string
-	linePrefix := "#"
+	var accountInfo ssoTypes.AccountInfo
+	if accountPattern != "" {
+		accountInfo, err = matchAccount(accountList, accountPattern)
+		if err != nil {
+			return ssoTypes.AccountInfo{}, err
+		}
+	} else {
+		sortedAccounts := sortAccounts(accountList)
 
-	for i, info := range sortedAccounts {
-		accountsToSelect = append(accountsToSelect, linePrefix+strconv.Itoa(i)+" "+*info.AccountName+" "+*info.AccountId)
-	}
+		var accountsToSelect []string
+		linePrefix := "#"
 
-	label := "Select your account - Hint: fuzzy search supported. To choose one account directly just enter #{Int}"
-	indexChoice, _, _ := selector.Select(label, accountsToSelect, fuzzySearchWithPrefixAnchor(accountsToSelect, linePrefix))
+		for i, info := range sortedAccounts {
+			accountsToSelect = append(accountsToSelect, linePrefix+strconv.Itoa(i)+" "+*info.AccountName+" "+*info.AccountId)
+		}
 
-	accountInfo := sortedAccounts[indexChoice]
+		label := "Select your account - Hint: fuzzy search supported. To choose one account directly just enter #{Int}"
+		indexChoice, _, err := selector.Select(label, accountsToSelect, fuzzySearchWithPrefixAnchor(accountsToSelect, linePrefix))
+		if err != nil {
+			return ssoTypes.AccountInfo{}, err
+		}
+
+		accountInfo = sortedAccounts[indexChoice]
+	}
 
 	log.Printf("Selected account: %s - %s", *accountInfo.AccountName, *accountInfo.AccountId)
-	return accountInfo
+	return accountInfo, nil
+}
+
+// ListAllAccounts lists every account the access token has access to, following all pages.
+func ListAllAccounts(clientInformation *ClientInformation, ssoClient SsoClient) ([]ssoTypes.AccountInfo, error) {
+	var accounts []ssoTypes.AccountInfo
+	paginator := sso.NewListAccountsPaginator(ssoClient, &sso.ListAccountsInput{AccessToken: &clientInformation.AccessToken})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		accounts = append(accounts, page.AccountList...)
+	}
+	return accounts, nil
+}
+
+// ListAllAccountRoles lists every role of the account the access token has access to, following all pages.
+func ListAllAccountRoles(accountId string, clientInformation *ClientInformation, ssoClient SsoClient) ([]ssoTypes.RoleInfo, error) {
+	var roles []ssoTypes.RoleInfo
+	paginator := sso.NewListAccountRolesPaginator(ssoClient, &sso.ListAccountRolesInput{AccountId: &accountId, AccessToken: &clientInformation.AccessToken})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles of account %s: %w", accountId, err)
+		}
+		roles = append(roles, page.RoleList...)
+	}
+	return roles, nil
 }
 
 func sortAccounts(accountList []ssoTypes.AccountInfo) []ssoTypes.AccountInfo {
@@ -0,0 +1,325 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	CacheBackendPlaintext = "plaintext"
+	CacheBackendEncrypted = "encrypted"
+
+	cachePassphraseEnvironmentVariable = "AWSX_CACHE_PASSPHRASE"
+
+	// encryptedCacheHeader starts every file written by the encrypted backend and carries the format version
+	encryptedCacheHeader = "awsx-encrypted-v2\n"
+	encryptedCachePrefix = "awsx-encrypted-"
+	saltLength           = 16
+	keyLength            = 32
+
+	// Argon2id parameters as recommended in RFC 9106 for memory constrained environments
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+var CacheBackends = []string{CacheBackendPlaintext, CacheBackendEncrypted}
+
+var ErrCacheKey = errors.New("cannot decrypt the access token cache")
+
+var ErrPlaintextTokenCache = errors.New("access tokens shared with the AWS CLI are stored in plaintext and can't be combined with the encrypted cache backend")
+
+// CacheSettings select the storage of the access token cache. They are set in the cache section of the awsx configuration.
+type CacheSettings struct {
+	Backend    string `yaml:"backend,omitempty"`
+	KeyFile    string `yaml:"key_file,omitempty"`
+	KeyCommand string `yaml:"key_command,omitempty"`
+}
+
+func (s *CacheSettings) GetBackend() string {
+	if s == nil || s.Backend == "" {
+		return CacheBackendPlaintext
+	}
+	return s.Backend
+}
+
+// CacheStore reads and writes the content of a cache file.
+type CacheStore interface {
+	Read(fileName string) ([]byte, error)
+	Write(fileName string, content []byte) error
+}
+
+// NewCacheStore returns the store for the backend of the settings.
+func NewCacheStore(settings *CacheSettings) (CacheStore, error) {
+	switch settings.GetBackend() {
+	case CacheBackendPlaintext:
+		return PlaintextCacheStore{}, nil
+	case CacheBackendEncrypted:
+		return &EncryptedCacheStore{Key: settings.key}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, must be one of %s", settings.Backend, strings.Join(CacheBackends, ", "))
+	}
+}
+
+// key returns the secret the encryption key is derived from: the output of the key command, which runs through
+// the shell, the content of the key file or the passphrase in AWSX_CACHE_PASSPHRASE, in that order.
+func (s *CacheSettings) key() ([]byte, error) {
+	switch {
+	case s.KeyCommand != "":
+		command := shellCommand(s.KeyCommand)
+		command.Stdin = os.Stdin
+		command.Stderr = os.Stderr
+		output, err := command.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%w: key command %q failed: %v", ErrCacheKey, s.KeyCommand, err)
+		}
+		return bytes.TrimRight(output, "\r\n"), nil
+	case s.KeyFile != "":
+		content, err := os.ReadFile(expandHome(s.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCacheKey, err)
+		}
+		return bytes.TrimRight(content, "\r\n"), nil
+	default:
+		passphrase := os.Getenv(cachePassphraseEnvironmentVariable)
+		if passphrase == "" {
+			return nil, fmt.Errorf("%w: set key_file or key_command in the cache settings, or %s", ErrCacheKey, cachePassphraseEnvironmentVariable)
+		}
+		return []byte(passphrase), nil
+	}
+}
+
+var cacheStoreMutex sync.Mutex
+var currentCacheStore CacheStore
+
+// cacheStore returns the store configured in the awsx configuration. It is created once per process.
+func cacheStore() (CacheStore, error) {
+	cacheStoreMutex.Lock()
+	defer cacheStoreMutex.Unlock()
+
+	if currentCacheStore == nil {
+		settings, err := ReadCacheSettings()
+		if err != nil {
+			return nil, err
+		}
+
+		currentCacheStore, err = NewCacheStore(settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return currentCacheStore, nil
+}
+
+// checkPlaintextTokenCache fails when the config shares its access token with the AWS CLI and the cache backend
+// is encrypted, as access tokens in the AWS CLI cache can only be stored in plaintext.
+func checkPlaintextTokenCache(config *Config) error {
+	reason := config.plaintextTokenCacheReason()
+	if reason == "" {
+		return nil
+	}
+
+	settings, err := ReadCacheSettings()
+	if err != nil {
+		return err
+	}
+	if settings.GetBackend() == CacheBackendEncrypted {
+		return fmt.Errorf("config %s with %s: %w", config.Name, reason, ErrPlaintextTokenCache)
+	}
+	return nil
+}
+
+// plaintextTokenCacheReason returns why the config writes its access token to the plaintext AWS CLI cache,
+// or an empty string when it doesn't.
+func (c *Config) plaintextTokenCacheReason() string {
+	if c.GetTokenCache() == TokenCacheAwsCli {
+		return "token_cache: " + TokenCacheAwsCli
+	}
+	if c.hasSsoSessionProfiles() {
+		return "output: " + ProfileOutputSsoSession + " profiles"
+	}
+	return ""
+}
+
+// MigrateCache rewrites the access token cache with the store of the given settings and saves the settings.
+func MigrateCache(settings *CacheSettings) error {
+	if settings.GetBackend() == CacheBackendEncrypted {
+		configs, _ := ReadInternalConfig()
+		for _, config := range configs {
+			if reason := config.plaintextTokenCacheReason(); reason != "" {
+				return fmt.Errorf("config %s with %s: %w", config.Name, reason, ErrPlaintextTokenCache)
+			}
+		}
+	}
+
+	from, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	to, err := NewCacheStore(settings)
+	if err != nil {
+		return err
+	}
+
+	fileName := clientInformationFileName()
+	return withFileLock(fileName, func() error {
+		content, err := from.Read(fileName)
+		if errors.Is(err, os.ErrNotExist) {
+			return WriteCacheSettings(settings)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = to.Write(fileName, content)
+		if err != nil {
+			return err
+		}
+
+		err = WriteCacheSettings(settings)
+		if err != nil {
+			// Keep the cache readable with the settings that are still in place
+			_ = from.Write(fileName, content)
+			return err
+		}
+
+		cacheStoreMutex.Lock()
+		currentCacheStore = to
+		cacheStoreMutex.Unlock()
+		return nil
+	})
+}
+
+type PlaintextCacheStore struct{}
+
+func (PlaintextCacheStore) Read(fileName string) ([]byte, error) {
+	content, err := os.ReadFile(fileName)
+	if err == nil && strings.HasPrefix(string(content), encryptedCachePrefix) {
+		return nil, fmt.Errorf("%w: %s is encrypted but the cache backend is %s", ErrCacheKey, fileName, CacheBackendPlaintext)
+	}
+	return content, err
+}
+
+func (PlaintextCacheStore) Write(fileName string, content []byte) error {
+	return writeCacheFile(fileName, content)
+}
+
+// EncryptedCacheStore encrypts with AES-256-GCM. The key is derived with Argon2id from the secret returned by
+// Key and a random salt stored in the file.
+type EncryptedCacheStore struct {
+	Key func() ([]byte, error)
+
+	mutex  sync.Mutex
+	secret []byte
+	keys   map[string][]byte
+}
+
+func (s *EncryptedCacheStore) Read(fileName string) ([]byte, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(string(content), encryptedCacheHeader):
+	case strings.HasPrefix(string(content), encryptedCachePrefix):
+		return nil, fmt.Errorf("%w: %s was written by a newer version of awsx", ErrCacheKey, fileName)
+	default:
+		return nil, fmt.Errorf("%w: %s is not encrypted, run awsx cache migrate", ErrCacheKey, fileName)
+	}
+	content = content[len(encryptedCacheHeader):]
+
+	if len(content) < saltLength {
+		return nil, fmt.Errorf("%w: %s is truncated", ErrCacheKey, fileName)
+	}
+	aead, err := s.cipher(content[:saltLength])
+	if err != nil {
+		return nil, err
+	}
+
+	content = content[saltLength:]
+	if len(content) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: %s is truncated", ErrCacheKey, fileName)
+	}
+	plaintext, err := aead.Open(nil, content[:aead.NonceSize()], content[aead.NonceSize():], []byte(encryptedCacheHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong key or corrupted file %s", ErrCacheKey, fileName)
+	}
+
+	return plaintext, nil
+}
+
+func (s *EncryptedCacheStore) Write(fileName string, content []byte) error {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+
+	aead, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	encrypted := append([]byte(encryptedCacheHeader), salt...)
+	encrypted = append(encrypted, nonce...)
+	encrypted = aead.Seal(encrypted, nonce, content, []byte(encryptedCacheHeader))
+
+	return writeCacheFile(fileName, encrypted)
+}
+
+// writeCacheFile writes the file readable by the owner only, also when an older version of awsx created it with wider permissions
+func writeCacheFile(fileName string, content []byte) error {
+	err := writeFileAtomic(fileName, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(fileName, 0600)
+}
+
+// cipher returns the cipher for a file with the given salt
+func (s *EncryptedCacheStore) cipher(salt []byte) (cipher.AEAD, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The key command may prompt, so it runs once per process
+	if s.secret == nil {
+		secret, err := s.Key()
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("%w: the key is empty", ErrCacheKey)
+		}
+		s.secret = secret
+		s.keys = make(map[string][]byte)
+	}
+
+	key, exists := s.keys[string(salt)]
+	if !exists {
+		key = argon2.IDKey(s.secret, salt, argon2Time, argon2Memory, argon2Threads, keyLength)
+		s.keys[string(salt)] = key
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func staticKey(key string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return []byte(key), nil
+	}
+}
+
+func TestEncryptedCacheStoreRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "access-token")
+	store := &EncryptedCacheStore{Key: staticKey("secret")}
+
+	err := store.Write(fileName, []byte("access_token: token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(fileName)
+	if !strings.HasPrefix(string(content), encryptedCacheHeader) || strings.Contains(string(content), "token") {
+		t.Fatalf("file is not encrypted: %q", content)
+	}
+	if info, _ := os.Stat(fileName); info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	plaintext, err := store.Read(fileName)
+	if err != nil || string(plaintext) != "access_token: token" {
+		t.Fatalf("Read() = %q, %v", plaintext, err)
+	}
+
+	_, err = (&EncryptedCacheStore{Key: staticKey("wrong")}).Read(fileName)
+	if !errors.Is(err, ErrCacheKey) {
+		t.Fatalf("Read() with the wrong key = %v, want ErrCacheKey", err)
+	}
+
+	_, err = PlaintextCacheStore{}.Read(fileName)
+	if !errors.Is(err, ErrCacheKey) {
+		t.Fatalf("plaintext Read() of an encrypted file = %v, want ErrCacheKey", err)
+	}
+}
+
+func TestEncryptedCacheStoreRejectsPlaintext(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "access-token")
+	err := os.WriteFile(fileName, []byte("access_token: token"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = (&EncryptedCacheStore{Key: staticKey("secret")}).Read(fileName)
+	if !errors.Is(err, ErrCacheKey) {
+		t.Fatalf("Read() = %v, want ErrCacheKey", err)
+	}
+}
+
+func TestCacheSettingsKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "awsx key")
+	err := os.WriteFile(keyFile, []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		settings   CacheSettings
+		passphrase string
+		want       string
+		wantErr    bool
+	}{
+		{name: "key command", settings: CacheSettings{KeyCommand: "echo from-command"}, want: "from-command"},
+		{name: "key command with quoted arguments", settings: CacheSettings{KeyCommand: `printf '%s' "awsx key"`}, want: "awsx key"},
+		{name: "key command with a pipe", settings: CacheSettings{KeyCommand: "echo from-pipe | tr a-z A-Z"}, want: "FROM-PIPE"},
+		{name: "failing key command", settings: CacheSettings{KeyCommand: "exit 3"}, wantErr: true},
+		{name: "key file with spaces in its name", settings: CacheSettings{KeyFile: keyFile}, want: "from-file"},
+		{name: "key command before key file", settings: CacheSettings{KeyCommand: "echo from-command", KeyFile: keyFile}, want: "from-command"},
+		{name: "passphrase", passphrase: "from-environment", want: "from-environment"},
+		{name: "no key", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.settings.KeyCommand != "" && runtime.GOOS == "windows" {
+				t.Skip("the key commands are written for sh")
+			}
+			t.Setenv(cachePassphraseEnvironmentVariable, test.passphrase)
+			key, err := test.settings.key()
+			if test.wantErr {
+				if !errors.Is(err, ErrCacheKey) {
+					t.Fatalf("key() = %q, %v, want ErrCacheKey", key, err)
+				}
+				return
+			}
+			if err != nil || string(key) != test.want {
+				t.Fatalf("key() = %q, %v, want %q", key, err, test.want)
+			}
+		})
+	}
+}
+
+func TestAwsCliTokenCacheRejectsEncryption(t *testing.T) {
+	useTempHome(t)
+	config := &Config{Name: "default", Id: "d-1234567890", SsoRegion: "eu-west-1", TokenCache: TokenCacheAwsCli}
+	err := UpdateInternalConfig(func(configs map[string]*Config) error {
+		configs["default"] = config
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateCache(&CacheSettings{Backend: CacheBackendEncrypted})
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("MigrateCache() = %v, want ErrPlaintextTokenCache", err)
+	}
+
+	err = WriteCacheSettings(&CacheSettings{Backend: CacheBackendEncrypted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadClientInformation(config)
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("LoadClientInformation() = %v, want ErrPlaintextTokenCache", err)
+	}
+	err = StoreClientInformation(config, &ClientInformation{})
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("StoreClientInformation() = %v, want ErrPlaintextTokenCache", err)
+	}
+}
+
+func TestSsoSessionProfilesRejectEncryption(t *testing.T) {
+	useTempHome(t)
+	config := &Config{
+		Name:      "default",
+		Id:        "d-1234567890",
+		SsoRegion: "eu-west-1",
+		Profiles:  map[string]*Profile{"dev": {Name: "dev", Region: "eu-west-1", Output: ProfileOutputSsoSession}},
+	}
+	err := UpdateInternalConfig(func(configs map[string]*Config) error {
+		configs["default"] = config
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateCache(&CacheSettings{Backend: CacheBackendEncrypted})
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("MigrateCache() = %v, want ErrPlaintextTokenCache", err)
+	}
+
+	err = WriteCacheSettings(&CacheSettings{Backend: CacheBackendEncrypted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadClientInformation(config)
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("LoadClientInformation() = %v, want ErrPlaintextTokenCache", err)
+	}
+	err = StoreClientInformation(config, &ClientInformation{AccessToken: "token", RefreshToken: "refresh"})
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("StoreClientInformation() = %v, want ErrPlaintextTokenCache", err)
+	}
+	err = WriteSsoSessionProfile(config, config.Profiles["dev"], &ClientInformation{AccessToken: "token"}, &UsageInformation{AccountId: "123456789012", Role: "Admin"})
+	if !errors.Is(err, ErrPlaintextTokenCache) {
+		t.Fatalf("WriteSsoSessionProfile() = %v, want ErrPlaintextTokenCache", err)
+	}
+
+	entries, _ := os.ReadDir(awsSsoCachePath())
+	if len(entries) > 0 {
+		t.Errorf("%d files were written to the AWS CLI cache", len(entries))
+	}
+}
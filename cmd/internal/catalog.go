@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/gerdou/awsx/version"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultCatalogTtl = 12 * time.Hour
+
+type CatalogAccount struct {
+	AccountId   string `yaml:"account_id"`
+	AccountName string `yaml:"account_name"`
+}
+
+type CatalogRoles struct {
+	UpdatedAt time.Time `yaml:"updated_at"`
+	RoleNames []string  `yaml:"role_names"`
+}
+
+type Catalog struct {
+	UpdatedAt time.Time                `yaml:"updated_at"`
+	Accounts  []CatalogAccount         `yaml:"accounts"`
+	Roles     map[string]*CatalogRoles `yaml:"roles"`
+}
+
+type CatalogFile struct {
+	Version  string              `yaml:"version"`
+	Catalogs map[string]*Catalog `yaml:"catalogs"`
+}
+
+// AccountCatalog lists the accounts and roles of a config, served from the on-disk catalog
+// until it is older than the TTL of the config or a refresh is requested.
+type AccountCatalog struct {
+	config            *Config
+	clientInformation *ClientInformation
+	ssoClient         SsoClient
+	refresh           bool
+}
+
+func NewAccountCatalog(config *Config, clientInformation *ClientInformation, ssoClient SsoClient, refresh bool) *AccountCatalog {
+	return &AccountCatalog{
+		config:            config,
+		clientInformation: clientInformation,
+		ssoClient:         ssoClient,
+		refresh:           refresh,
+	}
+}
+
+func (c *AccountCatalog) Accounts() ([]ssoTypes.AccountInfo, error) {
+	catalogFile, _ := ReadCatalogFile()
+	catalog, exists := catalogFile.Catalogs[c.config.Name]
+	if exists && !c.refresh && time.Since(catalog.UpdatedAt) < c.config.GetCatalogTtl() {
+		accounts := make([]ssoTypes.AccountInfo, 0, len(catalog.Accounts))
+		for _, account := range catalog.Accounts {
+			accounts = append(accounts, ssoTypes.AccountInfo{AccountId: aws.String(account.AccountId), AccountName: aws.String(account.AccountName)})
+		}
+		return accounts, nil
+	}
+
+	accounts, err := ListAllAccounts(c.clientInformation, c.ssoClient)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog = &Catalog{UpdatedAt: time.Now(), Roles: make(map[string]*CatalogRoles)}
+	for _, account := range accounts {
+		catalog.Accounts = append(catalog.Accounts, CatalogAccount{AccountId: aws.ToString(account.AccountId), AccountName: aws.ToString(account.AccountName)})
+	}
+	_ = updateCatalogFile(func(catalogFile *CatalogFile) {
+		catalogFile.Catalogs[c.config.Name] = catalog
+	})
+
+	return accounts, nil
+}
+
+func (c *AccountCatalog) Roles(accountId string) ([]ssoTypes.RoleInfo, error) {
+	catalogFile, _ := ReadCatalogFile()
+	catalog, exists := catalogFile.Catalogs[c.config.Name]
+	if exists && catalog.Roles != nil && !c.refresh {
+		if roles, exists := catalog.Roles[accountId]; exists && time.Since(roles.UpdatedAt) < c.config.GetCatalogTtl() {
+			roleInfos := make([]ssoTypes.RoleInfo, 0, len(roles.RoleNames))
+			for _, roleName := range roles.RoleNames {
+				roleInfos = append(roleInfos, ssoTypes.RoleInfo{AccountId: aws.String(accountId), RoleName: aws.String(roleName)})
+			}
+			return roleInfos, nil
+		}
+	}
+
+	roles, err := ListAllAccountRoles(accountId, c.clientInformation, c.ssoClient)
+	if err != nil {
+		return nil, err
+	}
+
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, aws.ToString(role.RoleName))
+	}
+	_ = updateCatalogFile(func(catalogFile *CatalogFile) {
+		catalog, exists := catalogFile.Catalogs[c.config.Name]
+		if !exists {
+			// Roles are only cached alongside the accounts they belong to
+			return
+		}
+		if catalog.Roles == nil {
+			catalog.Roles = make(map[string]*CatalogRoles)
+		}
+		catalog.Roles[accountId] = &CatalogRoles{UpdatedAt: time.Now(), RoleNames: roleNames}
+	})
+
+	return roles, nil
+}
+
+func ReadCatalogFile() (*CatalogFile, error) {
+	emptyCatalogFile := &CatalogFile{
+		Version:  version.Version,
+		Catalogs: make(map[string]*Catalog),
+	}
+
+	file, err := os.ReadFile(catalogFileName())
+	if err != nil {
+		return emptyCatalogFile, err
+	}
+
+	catalogFile := &CatalogFile{}
+	err = yaml.Unmarshal(file, catalogFile)
+	if err != nil || catalogFile.Catalogs == nil {
+		return emptyCatalogFile, err
+	}
+
+	return catalogFile, nil
+}
+
+// updateCatalogFile applies update to the latest catalog file and writes it. The lock is held from the read to
+// the write, so that concurrent selects keep each other's entries.
+func updateCatalogFile(update func(catalogFile *CatalogFile)) error {
+	err := os.MkdirAll(CachePath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(catalogFileName(), func() error {
+		catalogFile, _ := ReadCatalogFile()
+		update(catalogFile)
+
+		catalogFile.Version = version.Version
+		content, err := yaml.Marshal(catalogFile)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(catalogFileName(), content, 0600)
+	})
+}
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+	"gopkg.in/yaml.v3"
+)
+
+// newTestCatalog returns a catalog of the config against a fake with one account, logged in.
+func newTestCatalog(t *testing.T, config *Config, refresh bool) (*AccountCatalog, *fakesso.Server) {
+	t.Helper()
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin", "ReadOnly"}})
+	clientInformation, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAccountCatalog(config, clientInformation, fake.Sso(), refresh), fake
+}
+
+// ageTestCatalog makes the cached accounts and roles of the config look updated age ago.
+func ageTestCatalog(t *testing.T, configName string, age time.Duration) {
+	t.Helper()
+	err := updateCatalogFile(func(catalogFile *CatalogFile) {
+		catalog := catalogFile.Catalogs[configName]
+		catalog.UpdatedAt = time.Now().Add(-age)
+		for _, roles := range catalog.Roles {
+			roles.UpdatedAt = time.Now().Add(-age)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func listTestCatalog(t *testing.T, catalog *AccountCatalog) {
+	t.Helper()
+	if _, err := catalog.Accounts(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Roles("111111111111"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountCatalogTtl(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		age       time.Duration
+		wantCalls int
+	}{
+		{name: "fresh", age: time.Hour, wantCalls: 1},
+		{name: "expired", age: 13 * time.Hour, wantCalls: 2},
+		{name: "configured ttl fresh", ttl: 24 * time.Hour, age: 13 * time.Hour, wantCalls: 1},
+		{name: "configured ttl expired", ttl: time.Minute, age: 2 * time.Minute, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			config := newTestConfig()
+			config.CatalogTtl = tt.ttl
+			catalog, fake := newTestCatalog(t, config, false)
+
+			listTestCatalog(t, catalog)
+			ageTestCatalog(t, config.Name, tt.age)
+			listTestCatalog(t, catalog)
+
+			if calls := fake.Calls("ListAccounts"); calls != tt.wantCalls {
+				t.Errorf("%d calls to ListAccounts, want %d", calls, tt.wantCalls)
+			}
+			if calls := fake.Calls("ListAccountRoles"); calls != tt.wantCalls {
+				t.Errorf("%d calls to ListAccountRoles, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAccountCatalogRefresh(t *testing.T) {
+	useTempHome(t)
+	config := newTestConfig()
+	catalog, fake := newTestCatalog(t, config, true)
+
+	listTestCatalog(t, catalog)
+	listTestCatalog(t, catalog)
+	if calls := fake.Calls("ListAccounts"); calls != 2 {
+		t.Errorf("%d calls to ListAccounts, want 2 with refresh", calls)
+	}
+	if calls := fake.Calls("ListAccountRoles"); calls != 2 {
+		t.Errorf("%d calls to ListAccountRoles, want 2 with refresh", calls)
+	}
+
+	// The refreshed listing is cached for selections without --refresh-catalog
+	selector := Selector{Prompt: NoInputPrompter{}, Account: "dev", Role: "Admin"}
+	err := selector.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls("ListAccounts"); calls != 2 {
+		t.Errorf("%d calls to ListAccounts, want the cached accounts", calls)
+	}
+
+	selector.RefreshCatalog = true
+	err = selector.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls("ListAccounts"); calls != 3 {
+		t.Errorf("%d calls to ListAccounts, want another listing with RefreshCatalog", calls)
+	}
+}
+
+func TestConfigCatalogTtl(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{yaml: "sso_region: eu-west-1", want: defaultCatalogTtl},
+		{yaml: "catalog_ttl: 1h", want: time.Hour},
+		{yaml: "catalog_ttl: 90m", want: 90 * time.Minute},
+		{yaml: "catalog_ttl: 0s", want: defaultCatalogTtl},
+		{yaml: "catalog_ttl: soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.yaml, func(t *testing.T) {
+			config := &Config{}
+			err := yaml.Unmarshal([]byte(tt.yaml), config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("yaml.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if ttl := config.GetCatalogTtl(); ttl != tt.want {
+				t.Errorf("GetCatalogTtl() = %s, want %s", ttl, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCatalogFileKeepsConcurrentUpdates(t *testing.T) {
+	useTempHome(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := updateCatalogFile(func(catalogFile *CatalogFile) {
+				catalogFile.Catalogs[fmt.Sprintf("config-%d", i)] = &Catalog{UpdatedAt: time.Now()}
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	catalogFile, err := ReadCatalogFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(catalogFile.Catalogs); got != 10 {
+		t.Fatalf("got %d catalogs, want 10", got)
+	}
+}
@@ -0,0 +1,6 @@
+package internal
+
+import "github.com/gerdou/awsx/cmd/internal/fakesso"
+
+var _ OidcClient = (*fakesso.OidcClient)(nil)
+var _ SsoClient = (*fakesso.SsoClient)(nil)
@@ -3,8 +3,13 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
 	"path"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
@@ -13,38 +18,200 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+const ProfileOutputCredentials = "credentials"
+const ProfileOutputSsoSession = "sso-session"
+
 type Profile struct {
 	Region         string            `yaml:"region"`
 	DefaultAccount *UsageInformation `yaml:"default_account,omitempty"`
+	Output         string            `yaml:"output,omitempty"`
+	Synced         bool              `yaml:"synced,omitempty"`
+	SyncHash       string            `yaml:"sync_hash,omitempty"`
+	AssumeRole     AssumeRoleChain   `yaml:"assume_role,omitempty"`
 	Name           string            `yaml:"-"`
 }
 
+func (p *Profile) GetOutput() string {
+	if p.Output == "" {
+		return ProfileOutputCredentials
+	}
+	return p.Output
+}
+
+const LoginFlowDeviceCode = "device_code"
+const LoginFlowAuthorizationCode = "authorization_code"
+
+var LoginFlows = []string{LoginFlowDeviceCode, LoginFlowAuthorizationCode}
+
+const TokenCacheAwsx = "awsx"
+const TokenCacheAwsCli = "aws-cli"
+
+var TokenCaches = []string{TokenCacheAwsx, TokenCacheAwsCli}
+
+const PartitionAws = "aws"
+const PartitionAwsUsGov = "aws-us-gov"
+const PartitionAwsCn = "aws-cn"
+
+var Partitions = []string{PartitionAws, PartitionAwsUsGov, PartitionAwsCn}
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+var accountIdPattern = regexp.MustCompile(`^\d{12}$`)
+
 type Config struct {
-	Id        string              `yaml:"Id"`
-	Profiles  map[string]*Profile `yaml:"profiles"`
-	SsoRegion string              `yaml:"sso_region"`
-	Complete  bool                `yaml:"-"`
-	Name      string              `yaml:"-"`
+	Id         string              `yaml:"Id"`
+	StartUrl   string              `yaml:"start_url,omitempty"`
+	Partition  string              `yaml:"partition,omitempty"`
+	Profiles   map[string]*Profile `yaml:"profiles"`
+	SsoRegion  string              `yaml:"sso_region"`
+	LoginFlow  string              `yaml:"login_flow,omitempty"`
+	TokenCache string              `yaml:"token_cache,omitempty"`
+	SsoSession string              `yaml:"sso_session,omitempty"`
+	CatalogTtl time.Duration       `yaml:"catalog_ttl,omitempty"`
+	// Network settings applied to the AWS SDK clients of the config
+	SsoEndpoint  string `yaml:"sso_endpoint,omitempty"`
+	OidcEndpoint string `yaml:"oidc_endpoint,omitempty"`
+	StsEndpoint  string `yaml:"sts_endpoint,omitempty"`
+	HttpsProxy   string `yaml:"https_proxy,omitempty"`
+	CaBundle     string `yaml:"ca_bundle,omitempty"`
+	UseFips      bool   `yaml:"use_fips,omitempty"`
+	UseDualStack bool   `yaml:"use_dual_stack,omitempty"`
+	RetryMode    string `yaml:"retry_mode,omitempty"`
+	Complete     bool   `yaml:"-"`
+	Name         string `yaml:"-"`
 }
 
+// GetStartUrl returns the configured start URL, or builds it from the Id for the partition of the config.
 func (c *Config) GetStartUrl() string {
-	return fmt.Sprintf("https://%s.awsapps.com/start", c.Id)
+	switch {
+	case c.StartUrl != "":
+		return c.StartUrl
+	case c.GetPartition() == PartitionAwsUsGov:
+		return fmt.Sprintf("https://start.us-gov-home.awsapps.com/directory/%s", c.Id)
+	case c.GetPartition() == PartitionAwsCn:
+		return fmt.Sprintf("https://%s.awsapps.cn/start", c.Id)
+	default:
+		return fmt.Sprintf("https://%s.awsapps.com/start", c.Id)
+	}
+}
+
+// GetPartition returns the configured partition, or the partition of the SSO region.
+func (c *Config) GetPartition() string {
+	if c.Partition == "" {
+		return regionPartition(c.SsoRegion)
+	}
+	return c.Partition
+}
+
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionAwsUsGov
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionAwsCn
+	default:
+		return PartitionAws
+	}
+}
+
+// Validate checks that the config has a usable start URL and that its SSO region belongs to its partition.
+func (c *Config) Validate() error {
+	if c.Id == "" && c.StartUrl == "" {
+		return fmt.Errorf("config %s needs an Id or a start_url", c.Name)
+	}
+
+	if c.StartUrl != "" {
+		startUrl, err := url.Parse(c.StartUrl)
+		if err != nil || startUrl.Scheme != "https" || startUrl.Host == "" {
+			return fmt.Errorf("start_url %q of config %s must be an https URL", c.StartUrl, c.Name)
+		}
+	}
+
+	if err := ValidateRegion(c.SsoRegion); err != nil {
+		return fmt.Errorf("sso_region of config %s: %w", c.Name, err)
+	}
+
+	if c.Partition != "" && !slices.Contains(Partitions, c.Partition) {
+		return fmt.Errorf("partition %q of config %s must be one of %s", c.Partition, c.Name, strings.Join(Partitions, ", "))
+	}
+
+	if partition := regionPartition(c.SsoRegion); partition != c.GetPartition() {
+		return fmt.Errorf("sso_region %s of config %s is in partition %s, not %s", c.SsoRegion, c.Name, partition, c.GetPartition())
+	}
+
+	if c.LoginFlow != "" && !slices.Contains(LoginFlows, c.LoginFlow) {
+		return fmt.Errorf("login_flow %q of config %s must be one of %s", c.LoginFlow, c.Name, strings.Join(LoginFlows, ", "))
+	}
+
+	if c.TokenCache != "" && !slices.Contains(TokenCaches, c.TokenCache) {
+		return fmt.Errorf("token_cache %q of config %s must be one of %s", c.TokenCache, c.Name, strings.Join(TokenCaches, ", "))
+	}
+
+	return c.validateNetworkSettings()
+}
+
+// ValidateRegion checks that the region looks like an AWS region, e.g. eu-west-1 or us-gov-west-1.
+func ValidateRegion(region string) error {
+	if !regionPattern.MatchString(region) {
+		return fmt.Errorf("invalid region %q, expected a region like eu-west-1", region)
+	}
+	return nil
+}
+
+// ValidateAccountId checks that the account ID consists of 12 digits.
+func ValidateAccountId(accountId string) error {
+	if !accountIdPattern.MatchString(accountId) {
+		return fmt.Errorf("invalid account ID %q, expected 12 digits", accountId)
+	}
+	return nil
+}
+
+func (c *Config) GetLoginFlow() string {
+	if c.LoginFlow == "" {
+		return LoginFlowDeviceCode
+	}
+	return c.LoginFlow
+}
+
+func (c *Config) GetTokenCache() string {
+	if c.TokenCache == "" {
+		return TokenCacheAwsx
+	}
+	return c.TokenCache
+}
+
+// GetSsoSession returns the name of the sso-session in ~/.aws/config the config shares its login with,
+// which is the config name unless sso_session is set.
+func (c *Config) GetSsoSession() string {
+	if c.SsoSession == "" {
+		return c.Name
+	}
+	return c.SsoSession
+}
+
+func (c *Config) GetCatalogTtl() time.Duration {
+	if c.CatalogTtl <= 0 {
+		return defaultCatalogTtl
+	}
+	return c.CatalogTtl
 }
 
 type ConfigFile struct {
 	Version string             `yaml:"version"`
+	Cache   *CacheSettings     `yaml:"cache,omitempty"`
 	Configs map[string]*Config `yaml:"configs"`
 }
 
 type ClientInformation struct {
 	AccessTokenExpiresAt    time.Time `yaml:"access_token_expires_at"`
 	AccessToken             string    `yaml:"access_token"`
+	RefreshToken            string    `yaml:"refresh_token,omitempty"`
 	ClientId                string    `yaml:"client_id"`
 	ClientSecret            string    `yaml:"client_secret"`
 	ClientSecretExpiresAt   time.Time `yaml:"client_secret_expires_at"`
 	DeviceCode              string    `yaml:"device_code"`
 	VerificationUriComplete string    `yaml:"verification_uri_complete"`
 	StartUrl                string    `yaml:"start_url"`
+	LoginFlow               string    `yaml:"login_flow,omitempty"`
 }
 
 type ClientInformationFile struct {
@@ -64,19 +231,8 @@ type LastUsageInformationFile struct {
 	LastUsageInformation map[string]map[string][]UsageInformation `yaml:"last_usage_information"`
 }
 
-var home, _ = os.UserHomeDir()
-var defaultAwsCredentialsPath = path.Join(home, ".aws")
-var defaultAwsCredentialsFileName = "credentials"
-
-var defaultInternalPath = path.Join(home, ".config/awsx")
-var defaultConfigFileName = path.Join(defaultInternalPath, "config")
-
-var defaultCachePath = path.Join(defaultInternalPath, "cache")
-var defaultClientInformationFileName = path.Join(defaultCachePath, "access-token")
-var defaultLastUsageFileName = path.Join(defaultCachePath, "last-usage")
-
 func ReadUsageInformationFile() (*LastUsageInformationFile, error) {
-	file, err := os.ReadFile(defaultLastUsageFileName)
+	file, err := os.ReadFile(lastUsageFileName())
 	if err != nil {
 		return &LastUsageInformationFile{
 			Version:              version.Version,
@@ -108,11 +264,17 @@ func GetUsageInformationForConfig(configName string) (map[string][]UsageInformat
 }
 
 func SaveUsageInformationForConfig(configName string, information *UsageInformation) error {
-	err := os.MkdirAll(defaultCachePath, 0700)
+	err := os.MkdirAll(CachePath(), 0700)
 	if err != nil {
 		return err
 	}
 
+	return withFileLock(lastUsageFileName(), func() error {
+		return saveUsageInformation(configName, information)
+	})
+}
+
+func saveUsageInformation(configName string, information *UsageInformation) error {
 	usageInformationFile, _ := ReadUsageInformationFile()
 	usageInformation, exists := usageInformationFile.LastUsageInformation[configName]
 	if !exists {
@@ -135,12 +297,23 @@ func SaveUsageInformationForConfig(configName string, information *UsageInformat
 	usageInformation[information.Profile] = unique
 	usageInformationFile.LastUsageInformation[configName] = usageInformation
 	content, err := yaml.Marshal(usageInformationFile)
+	if err != nil {
+		return err
+	}
 
-	return os.WriteFile(defaultLastUsageFileName, content, 0700)
+	return writeFileAtomic(lastUsageFileName(), content, 0700)
 }
 
 func ReadClientInformationFile() (*ClientInformationFile, error) {
-	file, err := os.ReadFile(defaultClientInformationFileName)
+	store, err := cacheStore()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := store.Read(clientInformationFileName())
+	if errors.Is(err, ErrCacheKey) {
+		return nil, err
+	}
 	if err != nil {
 		return &ClientInformationFile{
 			Version:           version.Version,
@@ -164,6 +337,9 @@ func GetClientInformationForConfig(configName string) (*ClientInformation, error
 	}
 
 	clientInformationFile, err := ReadClientInformationFile()
+	if errors.Is(err, ErrCacheKey) {
+		return nil, err
+	}
 	if err != nil {
 		return emptyClientInformation, nil
 	}
@@ -177,24 +353,30 @@ func GetClientInformationForConfig(configName string) (*ClientInformation, error
 }
 
 func SetClientInformationForConfig(configName string, clientInformation *ClientInformation) error {
-	err := os.MkdirAll(defaultCachePath, 0700)
+	err := os.MkdirAll(CachePath(), 0700)
 	if err != nil {
 		return err
 	}
 
-	existingClientInformationFile, err := ReadClientInformationFile()
-	if err != nil {
-		return err
-	}
+	return withFileLock(clientInformationFileName(), func() error {
+		existingClientInformationFile, err := ReadClientInformationFile()
+		if err != nil {
+			return err
+		}
 
-	existingClientInformationFile.ClientInformation[configName] = clientInformation
+		existingClientInformationFile.ClientInformation[configName] = clientInformation
 
-	content, err := yaml.Marshal(existingClientInformationFile)
-	if err != nil {
-		return err
-	}
+		content, err := yaml.Marshal(existingClientInformationFile)
+		if err != nil {
+			return err
+		}
 
-	return os.WriteFile(defaultClientInformationFileName, content, 0700)
+		store, err := cacheStore()
+		if err != nil {
+			return err
+		}
+		return store.Write(clientInformationFileName(), content)
+	})
 }
 
 func formatExpiration(roleCredentials *ssoTypes.RoleCredentials) string {
@@ -216,7 +398,19 @@ func WriteAwsConfigFile(profile string, configuration *Config, credentials *ssoT
 		return errors.New("region does not exist in the configuration")
 	}
 
-	file, err := os.OpenFile(path.Join(defaultAwsCredentialsPath, defaultAwsCredentialsFileName), os.O_CREATE, 0644)
+	fileName := awsCredentialsFileName()
+	err := os.MkdirAll(path.Dir(fileName), 0700)
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(fileName, func() error {
+		return writeAwsCredentials(fileName, profile, configuration, credentials)
+	})
+}
+
+func writeAwsCredentials(fileName string, profile string, configuration *Config, credentials *ssoTypes.RoleCredentials) error {
+	file, err := os.OpenFile(fileName, os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
@@ -224,7 +418,7 @@ func WriteAwsConfigFile(profile string, configuration *Config, credentials *ssoT
 		_ = file.Close()
 	}(file)
 
-	awsCredentialsFile, err := ini.Load(path.Join(defaultAwsCredentialsPath, defaultAwsCredentialsFileName))
+	awsCredentialsFile, err := ini.Load(fileName)
 	if err != nil {
 		return err
 	}
@@ -247,21 +441,132 @@ func WriteAwsConfigFile(profile string, configuration *Config, credentials *ssoT
 		profileSection.Key("aws_expiration").SetValue(formatExpiration(credentials))
 	}
 
-	err = awsCredentialsFile.SaveTo(path.Join(defaultAwsCredentialsPath, defaultAwsCredentialsFileName))
+	err = saveIniFileAtomic(awsCredentialsFile, fileName)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func ReadInternalConfig() (map[string]*Config, error) {
-	file, err := os.ReadFile(defaultConfigFileName)
+// awsConfigSectionName returns the section name used for a profile in ~/.aws/config,
+// where every profile but the default one is prefixed with "profile ".
+func awsConfigSectionName(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+// WriteCredentialProcessToAwsConfig sets the credential_process of the profile in ~/.aws/config and removes its
+// static credentials from ~/.aws/credentials, which the AWS SDKs would keep using instead.
+func WriteCredentialProcessToAwsConfig(profile string, command string) error {
+	err := updateAwsConfigFile(func(awsConfigFile *ini.File) error {
+		awsConfigFile.Section(awsConfigSectionName(profile)).Key("credential_process").SetValue(command)
+		return nil
+	})
 	if err != nil {
-		return make(map[string]*Config), err
+		return err
 	}
 
-	configFile := ConfigFile{}
-	err = yaml.Unmarshal(file, &configFile)
+	return RemoveProfileFromAwsCredentialsFile(profile)
+}
+
+func updateAwsConfigFile(update func(awsConfigFile *ini.File) error) error {
+	fileName := awsConfigFileName()
+	err := os.MkdirAll(path.Dir(fileName), 0700)
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(fileName, func() error {
+		file, err := os.OpenFile(fileName, os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		_ = file.Close()
+
+		awsConfigFile, err := ini.Load(fileName)
+		if err != nil {
+			return err
+		}
+
+		err = update(awsConfigFile)
+		if err != nil {
+			return err
+		}
+
+		return saveIniFileAtomic(awsConfigFile, fileName)
+	})
+}
+
+// RemoveProfileFromAwsCredentialsFile deletes the static credentials of a profile, which would
+// otherwise take precedence over the settings of the profile in ~/.aws/config.
+func RemoveProfileFromAwsCredentialsFile(profile string) error {
+	fileName := awsCredentialsFileName()
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return withFileLock(fileName, func() error {
+		awsCredentialsFile, err := ini.Load(fileName)
+		if err != nil {
+			return err
+		}
+
+		if !awsCredentialsFile.HasSection(profile) {
+			return nil
+		}
+		awsCredentialsFile.DeleteSection(profile)
+
+		return saveIniFileAtomic(awsCredentialsFile, fileName)
+	})
+}
+
+// errNoAwsCredentials is returned when ~/.aws/credentials has no section for a profile
+var errNoAwsCredentials = errors.New("no credentials in the AWS credentials file")
+
+// ReadAwsCredentialsExpiration returns the aws_expiration awsx wrote for the profile to ~/.aws/credentials.
+func ReadAwsCredentialsExpiration(profile string) (time.Time, error) {
+	awsCredentialsFile, err := ini.Load(awsCredentialsFileName())
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, fmt.Errorf("%w for profile %s", errNoAwsCredentials, profile)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	section, err := awsCredentialsFile.GetSection(profile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w for profile %s", errNoAwsCredentials, profile)
+	}
+
+	if !section.HasKey("aws_expiration") {
+		return time.Time{}, fmt.Errorf("no expiration found for profile %s", profile)
+	}
+
+	return time.Parse(time.RFC3339, section.Key("aws_expiration").String())
+}
+
+func readConfigFile() (*ConfigFile, error) {
+	file, err := os.ReadFile(configFileName())
+	if err != nil {
+		return nil, err
+	}
+
+	configFile := &ConfigFile{}
+	err = yaml.Unmarshal(file, configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return configFile, nil
+}
+
+func ReadInternalConfig() (map[string]*Config, error) {
+	configFile, err := readConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]*Config), err
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +583,7 @@ func ReadInternalConfig() (map[string]*Config, error) {
 }
 
 func ExportInternalConfig(exportPath string) error {
-	file, err := os.ReadFile(defaultConfigFileName)
+	file, err := os.ReadFile(configFileName())
 	if err != nil {
 		return err
 	}
@@ -308,11 +613,55 @@ func ImportInternalConfig(importPath string) error {
 }
 
 func WriteInternalConfig(input map[string]*Config) error {
-	err := os.MkdirAll(defaultInternalPath, 0700)
+	err := os.MkdirAll(InternalPath(), 0700)
 	if err != nil {
 		return err
 	}
 
+	return withFileLock(configFileName(), func() error {
+		return writeInternalConfig(input)
+	})
+}
+
+// UpdateInternalConfig reads the configs, lets update change them and writes them back, all while holding the
+// lock of the configuration file, so that concurrent commands don't lose each other's changes. Nothing is
+// written when update fails, and the configuration and the cache are removed when no config is left.
+func UpdateInternalConfig(update func(configs map[string]*Config) error) error {
+	err := os.MkdirAll(InternalPath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(configFileName(), func() error {
+		configs, err := ReadInternalConfig()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if configs == nil {
+			configs = make(map[string]*Config)
+		}
+
+		err = update(configs)
+		if err != nil {
+			return err
+		}
+
+		if len(configs) == 0 {
+			return removeInternalFiles()
+		}
+		for _, config := range configs {
+			config.Complete = true
+		}
+		return writeInternalConfig(configs)
+	})
+}
+
+func writeInternalConfig(input map[string]*Config) error {
+	var cacheSettings *CacheSettings
+	if configFile, err := readConfigFile(); err == nil {
+		cacheSettings = configFile.Cache
+	}
+
 	existingConfigs, _ := ReadInternalConfig()
 	if existingConfigs == nil {
 		existingConfigs = make(map[string]*Config)
@@ -332,57 +681,96 @@ func WriteInternalConfig(input map[string]*Config) error {
 
 	config, err := yaml.Marshal(ConfigFile{
 		Version: version.Version,
+		Cache:   cacheSettings,
 		Configs: configs,
 	})
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(defaultConfigFileName, config, 0700)
+	return writeFileAtomic(configFileName(), config, 0700)
 }
 
-func RemoveInternalConfig(configNames []string) error {
-	configs, _ := ReadInternalConfig()
+// ReadCacheSettings returns the cache section of the awsx configuration, which is nil when it isn't set.
+func ReadCacheSettings() (*CacheSettings, error) {
+	configFile, err := readConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for _, configName := range configNames {
-		if _, ok := configs[configName]; !ok {
-			continue
-		}
+	return configFile.Cache, nil
+}
 
-		delete(configs, configName)
+func WriteCacheSettings(settings *CacheSettings) error {
+	err := os.MkdirAll(InternalPath(), 0700)
+	if err != nil {
+		return err
 	}
 
-	if len(configs) == 0 {
-		return os.RemoveAll(defaultInternalPath)
-	}
+	return withFileLock(configFileName(), func() error {
+		configFile, err := readConfigFile()
+		if errors.Is(err, os.ErrNotExist) {
+			configFile = &ConfigFile{Configs: make(map[string]*Config)}
+		} else if err != nil {
+			return err
+		}
 
-	return WriteInternalConfig(configs)
-}
+		configFile.Version = version.Version
+		configFile.Cache = settings
+		if settings.GetBackend() == CacheBackendPlaintext && settings.KeyFile == "" && settings.KeyCommand == "" {
+			configFile.Cache = nil
+		}
 
-func RemoveProfilesFromConfig(configName string, profileNames []string) error {
-	configs, _ := ReadInternalConfig()
+		content, err := yaml.Marshal(configFile)
+		if err != nil {
+			return err
+		}
 
-	config, ok := configs[configName]
-	if !ok {
-		return nil
-	}
+		return writeFileAtomic(configFileName(), content, 0700)
+	})
+}
 
-	for _, profileName := range profileNames {
-		if _, exists := config.Profiles[profileName]; !exists {
-			continue
+func RemoveInternalConfig(configNames []string) error {
+	return UpdateInternalConfig(func(configs map[string]*Config) error {
+		for _, configName := range configNames {
+			delete(configs, configName)
 		}
-		delete(config.Profiles, profileName)
-	}
+		return nil
+	})
+}
 
-	if len(config.Profiles) == 0 {
-		delete(configs, configName)
-	} else {
-		configs[configName] = config
+// removeInternalFiles removes the configuration file and the cache instead of the whole configuration
+// directory, which may contain other files when set with --config-dir or AWSX_HOME.
+func removeInternalFiles() error {
+	err := os.RemoveAll(CachePath())
+	if err != nil {
+		return err
 	}
 
-	if len(configs) == 0 {
-		return os.RemoveAll(defaultInternalPath)
+	err = os.Remove(configFileName())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
+	return nil
+}
 
-	return WriteInternalConfig(configs)
+func RemoveProfilesFromConfig(configName string, profileNames []string) error {
+	return UpdateInternalConfig(func(configs map[string]*Config) error {
+		config, ok := configs[configName]
+		if !ok {
+			return nil
+		}
+
+		for _, profileName := range profileNames {
+			delete(config.Profiles, profileName)
+		}
+
+		if len(config.Profiles) == 0 {
+			delete(configs, configName)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// useTempHome points every file awsx reads or writes into a temporary directory.
+func useTempHome(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(awsxHomeEnvironmentVariable, filepath.Join(dir, "awsx"))
+	t.Setenv(awsConfigFileEnvironmentVariable, filepath.Join(dir, "aws", "config"))
+	t.Setenv(awsCredentialsFileEnvironmentVariable, filepath.Join(dir, "aws", "credentials"))
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	previousHome := home
+	home = dir
+	// Logins against the fake must not open a real browser
+	previousOpenUrlInBrowser := openUrlInBrowser
+	openUrlInBrowser = func(string) {}
+	// The cache store is created once per process from the configuration of the home
+	cacheStoreMutex.Lock()
+	currentCacheStore = nil
+	cacheStoreMutex.Unlock()
+	t.Cleanup(func() {
+		home = previousHome
+		openUrlInBrowser = previousOpenUrlInBrowser
+		cacheStoreMutex.Lock()
+		currentCacheStore = nil
+		cacheStoreMutex.Unlock()
+	})
+	return dir
+}
+
+func TestUpdateInternalConfigKeepsConcurrentUpdates(t *testing.T) {
+	useTempHome(t)
+	err := UpdateInternalConfig(func(configs map[string]*Config) error {
+		configs["default"] = &Config{Id: "d-1234567890", SsoRegion: "eu-west-1", Profiles: map[string]*Profile{}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := UpdateInternalConfig(func(configs map[string]*Config) error {
+				configs["default"].Profiles[fmt.Sprintf("profile-%d", i)] = &Profile{Region: "eu-west-1"}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	configs, err := ReadInternalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(configs["default"].Profiles); got != 10 {
+		t.Fatalf("got %d profiles, want 10", got)
+	}
+}
+
+func TestUpdateInternalConfigWritesNothingOnError(t *testing.T) {
+	useTempHome(t)
+	err := UpdateInternalConfig(func(configs map[string]*Config) error {
+		configs["default"] = &Config{Id: "d-1234567890", SsoRegion: "eu-west-1"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failure := fmt.Errorf("failed")
+	err = UpdateInternalConfig(func(configs map[string]*Config) error {
+		delete(configs, "default")
+		configs["other"] = &Config{Id: "d-0987654321", SsoRegion: "eu-west-1"}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("UpdateInternalConfig() = %v, want %v", err, failure)
+	}
+
+	configs, _ := ReadInternalConfig()
+	if _, exists := configs["default"]; !exists || len(configs) != 1 {
+		t.Fatalf("configs changed to %v", configs)
+	}
+}
+
+func TestRemoveProfilesFromConfig(t *testing.T) {
+	useTempHome(t)
+	err := UpdateInternalConfig(func(configs map[string]*Config) error {
+		configs["default"] = &Config{Id: "d-1234567890", SsoRegion: "eu-west-1", Profiles: map[string]*Profile{
+			"dev":  {Region: "eu-west-1"},
+			"prod": {Region: "eu-west-1"},
+		}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RemoveProfilesFromConfig("default", []string{"dev", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ := ReadInternalConfig()
+	if _, exists := configs["default"].Profiles["prod"]; !exists || len(configs["default"].Profiles) != 1 {
+		t.Fatalf("profiles = %v, want only prod", configs["default"].Profiles)
+	}
+
+	err = RemoveProfilesFromConfig("default", []string{"prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ = ReadInternalConfig()
+	if len(configs) != 0 {
+		t.Fatalf("configs = %v, want none", configs)
+	}
+}
+
+func TestConfigValidateLoginFlowAndTokenCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		loginFlow  string
+		tokenCache string
+		wantErr    bool
+	}{
+		{name: "defaults"},
+		{name: "device code", loginFlow: LoginFlowDeviceCode},
+		{name: "authorization code", loginFlow: LoginFlowAuthorizationCode},
+		{name: "unknown login flow", loginFlow: "implicit", wantErr: true},
+		{name: "awsx cache", tokenCache: TokenCacheAwsx},
+		{name: "aws cli cache", tokenCache: TokenCacheAwsCli},
+		{name: "unknown token cache", tokenCache: "keychain", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Name: "default", Id: "d-1234567890", SsoRegion: "eu-west-1", LoginFlow: tt.loginFlow, TokenCache: tt.tokenCache}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigStartUrlAndPartition(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		wantStartUrl  string
+		wantPartition string
+		wantErr       bool
+	}{
+		{
+			name:          "aws",
+			config:        Config{Id: "d-1234567890", SsoRegion: "eu-west-1"},
+			wantStartUrl:  "https://d-1234567890.awsapps.com/start",
+			wantPartition: PartitionAws,
+		},
+		{
+			name:          "aws-us-gov",
+			config:        Config{Id: "d-1234567890", SsoRegion: "us-gov-west-1"},
+			wantStartUrl:  "https://start.us-gov-home.awsapps.com/directory/d-1234567890",
+			wantPartition: PartitionAwsUsGov,
+		},
+		{
+			name:          "aws-cn",
+			config:        Config{Id: "d-1234567890", SsoRegion: "cn-north-1", Partition: PartitionAwsCn},
+			wantStartUrl:  "https://d-1234567890.awsapps.cn/start",
+			wantPartition: PartitionAwsCn,
+		},
+		{
+			name:          "start url",
+			config:        Config{Id: "d-1234567890", StartUrl: "https://sso.example.com/start", SsoRegion: "cn-north-1"},
+			wantStartUrl:  "https://sso.example.com/start",
+			wantPartition: PartitionAwsCn,
+		},
+		{
+			name:    "start url without https",
+			config:  Config{StartUrl: "http://sso.example.com/start", SsoRegion: "eu-west-1"},
+			wantErr: true,
+		},
+		{
+			name:    "no id or start url",
+			config:  Config{SsoRegion: "eu-west-1"},
+			wantErr: true,
+		},
+		{
+			name:    "region outside the partition",
+			config:  Config{Id: "d-1234567890", SsoRegion: "eu-west-1", Partition: PartitionAwsUsGov},
+			wantErr: true,
+		},
+		{
+			name:    "unknown partition",
+			config:  Config{Id: "d-1234567890", SsoRegion: "eu-west-1", Partition: "aws-iso"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Name = "default"
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if startUrl := tt.config.GetStartUrl(); startUrl != tt.wantStartUrl {
+				t.Errorf("GetStartUrl() = %s, want %s", startUrl, tt.wantStartUrl)
+			}
+			if partition := tt.config.GetPartition(); partition != tt.wantPartition {
+				t.Errorf("GetPartition() = %s, want %s", partition, tt.wantPartition)
+			}
+		})
+	}
+}
+
+func TestValidateRegion(t *testing.T) {
+	tests := []struct {
+		region  string
+		wantErr bool
+	}{
+		{region: "eu-west-1"},
+		{region: "us-gov-west-1"},
+		{region: "ap-southeast-2"},
+		{region: "cn-northwest-1"},
+		{region: "", wantErr: true},
+		{region: "eu-west", wantErr: true},
+		{region: "EU-WEST-1", wantErr: true},
+		{region: "eu-west-1a", wantErr: true},
+		{region: "europe-west-1", wantErr: true},
+		{region: " eu-west-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			err := ValidateRegion(tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRegion(%q) error = %v, wantErr %v", tt.region, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAccountId(t *testing.T) {
+	tests := []struct {
+		accountId string
+		wantErr   bool
+	}{
+		{accountId: "123456789012"},
+		{accountId: "000000000000"},
+		{accountId: "", wantErr: true},
+		{accountId: "12345678901", wantErr: true},
+		{accountId: "1234567890123", wantErr: true},
+		{accountId: "12345678901a", wantErr: true},
+		{accountId: "1234-5678-9012", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accountId, func(t *testing.T) {
+			err := ValidateAccountId(tt.accountId)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAccountId(%q) error = %v, wantErr %v", tt.accountId, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CredentialProcessOutput is the document the AWS SDKs expect on stdout from a credential_process command.
+type CredentialProcessOutput struct {
+	Version         int    `json:"Version"`
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+func CredentialProcess(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) ([]byte, error) {
+	roleCredentials, lui, err := RetrieveCredentials(config, profile, oidcClient, ssoClient)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = SaveUsageInformationForConfig(config.Name, lui)
+
+	return json.Marshal(CredentialProcessOutput{
+		Version:         1,
+		AccessKeyId:     *roleCredentials.AccessKeyId,
+		SecretAccessKey: *roleCredentials.SecretAccessKey,
+		SessionToken:    *roleCredentials.SessionToken,
+		Expiration:      formatExpiration(roleCredentials),
+	})
+}
+
+// CredentialProcessCommand returns the credential_process command line running the given awsx executable for
+// the profile. The arguments are quoted for the shell that the AWS SDKs run the command line with.
+func CredentialProcessCommand(executable string, configName string, profileName string) string {
+	arguments := []string{executable, "credential-process", configName, profileName}
+	for i, argument := range arguments {
+		arguments[i] = quoteCommandArgument(argument)
+	}
+	return strings.Join(arguments, " ")
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestCredentialProcessCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("expects /bin/sh quoting")
+	}
+
+	tests := []struct {
+		name       string
+		executable string
+		config     string
+		profile    string
+		want       string
+	}{
+		{
+			name:       "plain",
+			executable: "/usr/local/bin/awsx",
+			config:     "default",
+			profile:    "my-profile",
+			want:       "/usr/local/bin/awsx credential-process default my-profile",
+		},
+		{
+			name:       "spaces",
+			executable: "/Users/jane doe/bin/awsx",
+			config:     "my config",
+			profile:    "dev",
+			want:       "'/Users/jane doe/bin/awsx' credential-process 'my config' dev",
+		},
+		{
+			name:       "shell characters",
+			executable: "/opt/awsx/awsx",
+			config:     "it's",
+			profile:    "$(id);dev",
+			want:       `/opt/awsx/awsx credential-process 'it'\''s' '$(id);dev'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CredentialProcessCommand(tt.executable, tt.config, tt.profile)
+			if got != tt.want {
+				t.Fatalf("CredentialProcessCommand() = %s, want %s", got, tt.want)
+			}
+
+			// The shell that runs the command line sees the original arguments
+			output, err := shellCommand(`printf '%s\n' ` + got).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{tt.executable, "credential-process", tt.config, tt.profile}
+			if arguments := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"); !slices.Equal(arguments, want) {
+				t.Fatalf("the shell split the command line into %q, want %q", arguments, want)
+			}
+		})
+	}
+}
+
+func TestWriteCredentialProcessToAwsConfig(t *testing.T) {
+	useTempHome(t)
+	err := os.MkdirAll(filepath.Dir(awsCredentialsFileName()), 0700)
+	if err == nil {
+		err = os.WriteFile(awsCredentialsFileName(), []byte("[dev]\naws_access_key_id = AKIA\n\n[prod]\naws_access_key_id = AKIB\n"), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteCredentialProcessToAwsConfig("dev", "awsx credential-process default dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	awsConfigFile, err := ini.Load(awsConfigFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := awsConfigFile.Section("profile dev").Key("credential_process").String(); command != "awsx credential-process default dev" {
+		t.Errorf("credential_process = %q", command)
+	}
+
+	awsCredentialsFile, err := ini.Load(awsCredentialsFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if awsCredentialsFile.HasSection("dev") {
+		t.Error("the static credentials of dev were kept in the credentials file")
+	}
+	if !awsCredentialsFile.HasSection("prod") {
+		t.Error("the credentials of another profile were removed")
+	}
+}
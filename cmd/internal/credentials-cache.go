@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"sync"
+	"time"
+
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+// Credentials are refreshed once they are closer to their expiry than this
+const credentialsRefreshWindow = 5 * time.Minute
+
+// CredentialsCache hands out the role credentials of a profile and transparently retrieves
+// new ones shortly before they expire. It is safe for concurrent use.
+type CredentialsCache struct {
+	mutex       sync.Mutex
+	config      *Config
+	profile     *Profile
+	oidcClient  OidcClient
+	ssoClient   SsoClient
+	credentials *ssoTypes.RoleCredentials
+}
+
+func NewCredentialsCache(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) *CredentialsCache {
+	return &CredentialsCache{
+		config:     config,
+		profile:    profile,
+		oidcClient: oidcClient,
+		ssoClient:  ssoClient,
+	}
+}
+
+func (c *CredentialsCache) Get() (*ssoTypes.RoleCredentials, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.credentials != nil && time.UnixMilli(c.credentials.Expiration).After(time.Now().Add(credentialsRefreshWindow)) {
+		return c.credentials, nil
+	}
+
+	credentials, _, err := RetrieveCredentials(c.config, c.profile, c.oidcClient, c.ssoClient)
+	if err != nil {
+		return nil, err
+	}
+
+	c.credentials = credentials
+	return credentials, nil
+}
@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const daemonMinBackoff = 30 * time.Second
+const daemonMaxBackoff = 30 * time.Minute
+
+var ErrDaemonNotRunning = errors.New("the awsx daemon is not running")
+
+var unquotedSystemdArgumentPattern = regexp.MustCompile(`^[\w@+=:,./-]+$`)
+var systemdArgumentReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+
+// Daemon refreshes the credentials of every previously used profile shortly before they expire.
+type Daemon struct {
+	// LeadTime is how long before their expiry credentials are refreshed
+	LeadTime time.Duration
+	// Interval is how often the expiry of the credentials is checked
+	Interval time.Duration
+
+	failures map[string]int
+	retryAt  map[string]time.Time
+}
+
+func (d *Daemon) Run(ctx context.Context) error {
+	err := writeDaemonPidFile()
+	if err != nil {
+		return err
+	}
+	defer removeDaemonPidFile()
+
+	d.failures = make(map[string]int)
+	d.retryAt = make(map[string]time.Time)
+
+	log.Printf("Refreshing credentials %s before they expire", d.LeadTime)
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.refreshExpiring()
+
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping the awsx daemon")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Daemon) refreshExpiring() {
+	configs, err := ReadInternalConfig()
+	if err != nil {
+		log.Printf("Failed to read the awsx config: %v\n", err)
+		return
+	}
+
+	usageInformationFile, err := ReadUsageInformationFile()
+	if err != nil {
+		return
+	}
+
+	for configName, usageInformation := range usageInformationFile.LastUsageInformation {
+		config, exists := configs[configName]
+		if !exists {
+			continue
+		}
+
+		var oidcClient OidcClient
+		var ssoClient SsoClient
+		for profileName := range usageInformation {
+			profile, exists := config.Profiles[profileName]
+			if !exists || profile.GetOutput() != ProfileOutputCredentials || !d.isDue(config, profile) {
+				continue
+			}
+
+			if oidcClient == nil {
+				oidcClient, ssoClient, err = InitClients(config)
+				if err != nil {
+					log.Printf("Failed to create the AWS clients for config %s: %v\n", configName, err)
+					break
+				}
+			}
+
+			key := configName + "/" + profileName
+			err = RefreshWithoutPrompt(config, profile, oidcClient, ssoClient)
+			if err != nil {
+				backoff := d.backOff(key)
+				log.Printf("Failed to refresh profile %s in config %s, retrying in %s: %v\n", profileName, configName, backoff, err)
+				continue
+			}
+
+			delete(d.failures, key)
+			delete(d.retryAt, key)
+		}
+	}
+}
+
+// isDue tells whether the credentials awsx wrote for the profile to ~/.aws/credentials expire within the lead
+// time. Profiles without a section there are only used through credential_process, exec or env, which get
+// their own credentials, so the daemon doesn't write static credentials for them.
+func (d *Daemon) isDue(config *Config, profile *Profile) bool {
+	if retryAt, exists := d.retryAt[config.Name+"/"+profile.Name]; exists && time.Now().Before(retryAt) {
+		return false
+	}
+
+	expiration, err := ReadAwsCredentialsExpiration(profile.Name)
+	if errors.Is(err, errNoAwsCredentials) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	return time.Until(expiration) < d.LeadTime
+}
+
+// backOff records a failed refresh of the profile and returns how long to wait before the next attempt,
+// doubling from daemonMinBackoff up to daemonMaxBackoff with every consecutive failure.
+func (d *Daemon) backOff(key string) time.Duration {
+	d.failures[key]++
+	backoff := daemonMinBackoff << (d.failures[key] - 1)
+	if backoff > daemonMaxBackoff || backoff <= 0 {
+		backoff = daemonMaxBackoff
+	}
+	d.retryAt[key] = time.Now().Add(backoff)
+	return backoff
+}
+
+// writeDaemonPidFile records the pid of this process unless another daemon is running. The check and the write
+// happen under the lock of the pid file, so that of two daemons started at the same time only one runs.
+func writeDaemonPidFile() error {
+	return withFileLock(daemonPidFileName(), func() error {
+		if pid, err := RunningDaemonPid(); err == nil {
+			return fmt.Errorf("the awsx daemon is already running with pid %d", pid)
+		}
+
+		return writeFileAtomic(daemonPidFileName(), []byte(strconv.Itoa(os.Getpid())), 0600)
+	})
+}
+
+// removeDaemonPidFile removes the pid file if it still holds the pid of this process.
+func removeDaemonPidFile() {
+	_ = withFileLock(daemonPidFileName(), func() error {
+		if pid, err := RunningDaemonPid(); err == nil && pid == os.Getpid() {
+			return os.Remove(daemonPidFileName())
+		}
+		return nil
+	})
+}
+
+// RunningDaemonPid returns the pid of the running daemon or ErrDaemonNotRunning.
+func RunningDaemonPid() (int, error) {
+	content, err := os.ReadFile(daemonPidFileName())
+	if err != nil {
+		return 0, ErrDaemonNotRunning
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || !processAlive(pid) {
+		return 0, ErrDaemonNotRunning
+	}
+
+	return pid, nil
+}
+
+func StopDaemon() (int, error) {
+	pid, err := RunningDaemonPid()
+	if err != nil {
+		return 0, err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return pid, terminateProcess(process)
+}
+
+// DaemonSystemdUnit returns a systemd user unit that runs the daemon with the given executable.
+// Overridden file locations are passed on, as the unit doesn't inherit the environment of the shell.
+func DaemonSystemdUnit(executable string, leadTime time.Duration) string {
+	var environment strings.Builder
+	if internalPath := internalPathOverride(); internalPath != "" {
+		_, _ = fmt.Fprintf(&environment, "Environment=%s\n", quoteSystemdArgument(awsxHomeEnvironmentVariable+"="+internalPath))
+	}
+	for _, name := range []string{awsConfigFileEnvironmentVariable, awsCredentialsFileEnvironmentVariable, "XDG_CONFIG_HOME", "XDG_CACHE_HOME"} {
+		if value := environmentPath(name); value != "" {
+			_, _ = fmt.Fprintf(&environment, "Environment=%s\n", quoteSystemdArgument(name+"="+value))
+		}
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=awsx credentials auto-refresh daemon
+
+[Service]
+%sExecStart=%s daemon --lead-time %s
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`, environment.String(), quoteSystemdArgument(executable), leadTime)
+}
+
+// quoteSystemdArgument quotes the argument for a systemd unit unless it consists of characters without special
+// meaning. Besides quotes and backslashes, systemd expands % specifiers and $ variables, even inside quotes.
+func quoteSystemdArgument(argument string) string {
+	if unquotedSystemdArgumentPattern.MatchString(argument) {
+		return argument
+	}
+	return `"` + systemdArgumentReplacer.Replace(argument) + `"`
+}
+
+func DaemonSystemdUnitPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = path.Join(home, ".config")
+	}
+	return path.Join(configHome, "systemd", "user", "awsx-daemon.service")
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteDaemonPidFileOnlyOnce(t *testing.T) {
+	useTempHome(t)
+
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if writeDaemonPidFile() == nil {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if started.Load() != 1 {
+		t.Fatalf("%d daemons started, want exactly one", started.Load())
+	}
+	if pid, err := RunningDaemonPid(); err != nil || pid != os.Getpid() {
+		t.Fatalf("RunningDaemonPid() = %d, %v, want %d", pid, err, os.Getpid())
+	}
+
+	removeDaemonPidFile()
+	if _, err := RunningDaemonPid(); !errors.Is(err, ErrDaemonNotRunning) {
+		t.Fatalf("RunningDaemonPid() error = %v after removing the pid file, want ErrDaemonNotRunning", err)
+	}
+}
+
+func TestRemoveDaemonPidFileKeepsOtherDaemon(t *testing.T) {
+	useTempHome(t)
+	// The pid of a process that is alive but isn't this one
+	err := os.MkdirAll(CachePath(), 0700)
+	if err == nil {
+		err = os.WriteFile(daemonPidFileName(), []byte("1"), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removeDaemonPidFile()
+	if _, err := os.Stat(daemonPidFileName()); err != nil {
+		t.Fatalf("the pid file of another daemon was removed: %v", err)
+	}
+}
+
+func TestDaemonIsDue(t *testing.T) {
+	writeCredentials := func(t *testing.T, content string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(awsCredentialsFileName()), 0700)
+		if err == nil {
+			err = os.WriteFile(awsCredentialsFileName(), []byte(content), 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	section := func(expiration time.Duration) string {
+		return "[dev]\naws_access_key_id = AKIA\naws_expiration = " + time.Now().Add(expiration).UTC().Format(time.RFC3339) + "\n"
+	}
+
+	tests := []struct {
+		name        string
+		credentials string
+		retryAt     time.Duration
+		want        bool
+	}{
+		{name: "no credentials file", want: false},
+		{name: "credentials written elsewhere", credentials: "[prod]\naws_access_key_id = AKIA\n", want: false},
+		{name: "no expiration", credentials: "[dev]\naws_access_key_id = AKIA\n", want: true},
+		{name: "expiring within the lead time", credentials: section(5 * time.Minute), want: true},
+		{name: "expired", credentials: section(-time.Minute), want: true},
+		{name: "expiring after the lead time", credentials: section(time.Hour), want: false},
+		{name: "backing off", credentials: section(5 * time.Minute), retryAt: time.Minute, want: false},
+		{name: "backoff passed", credentials: section(5 * time.Minute), retryAt: -time.Second, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			if tt.credentials != "" {
+				writeCredentials(t, tt.credentials)
+			}
+
+			d := &Daemon{LeadTime: 10 * time.Minute, retryAt: make(map[string]time.Time)}
+			if tt.retryAt != 0 {
+				d.retryAt["work/dev"] = time.Now().Add(tt.retryAt)
+			}
+
+			config := &Config{Name: "work"}
+			profile := &Profile{Name: "dev"}
+			if got := d.isDue(config, profile); got != tt.want {
+				t.Errorf("isDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonBackOff(t *testing.T) {
+	d := &Daemon{failures: make(map[string]int), retryAt: make(map[string]time.Time)}
+
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	for i, wantBackoff := range want {
+		before := time.Now()
+		backoff := d.backOff("work/dev")
+		if backoff != wantBackoff {
+			t.Errorf("failure %d: backoff = %s, want %s", i+1, backoff, wantBackoff)
+		}
+		if retryAt := d.retryAt["work/dev"]; retryAt.Before(before.Add(backoff)) || retryAt.After(time.Now().Add(backoff)) {
+			t.Errorf("failure %d: retry at %s, want %s from now", i+1, retryAt, backoff)
+		}
+	}
+
+	// Many failures keep the maximum instead of overflowing the shift
+	d.failures["work/dev"] = 100
+	if backoff := d.backOff("work/dev"); backoff != daemonMaxBackoff {
+		t.Errorf("backoff after 101 failures = %s, want %s", backoff, daemonMaxBackoff)
+	}
+
+	if backoff := d.backOff("work/prod"); backoff != daemonMinBackoff {
+		t.Errorf("backoff of another profile = %s, want %s", backoff, daemonMinBackoff)
+	}
+}
+
+func TestDaemonSystemdUnitQuotesPaths(t *testing.T) {
+	useTempHome(t)
+	t.Setenv(awsxHomeEnvironmentVariable, "/home/me/my awsx")
+
+	unit := DaemonSystemdUnit("/opt/my apps/100%/awsx", 5*time.Minute)
+	for _, want := range []string{
+		`Environment="AWSX_HOME=/home/me/my awsx"`,
+		`ExecStart="/opt/my apps/100%%/awsx" daemon --lead-time 5m0s`,
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit does not contain %s:\n%s", want, unit)
+		}
+	}
+
+	unit = DaemonSystemdUnit("/usr/local/bin/awsx", 5*time.Minute)
+	if !strings.Contains(unit, "ExecStart=/usr/local/bin/awsx daemon") {
+		t.Errorf("a plain path is quoted:\n%s", unit)
+	}
+}
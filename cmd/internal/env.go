@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var Shells = []string{"bash", "zsh", "fish", "powershell", "dotenv", "json"}
+
+func FormatEnvironment(shell string, environment []EnvironmentVariable) (string, error) {
+	var builder strings.Builder
+	switch shell {
+	case "bash", "zsh":
+		for _, variable := range environment {
+			builder.WriteString(fmt.Sprintf("export %s=%s\n", variable.Name, quotePosix(variable.Value)))
+		}
+	case "fish":
+		for _, variable := range environment {
+			builder.WriteString(fmt.Sprintf("set -gx %s %s;\n", variable.Name, quoteFish(variable.Value)))
+		}
+	case "powershell":
+		for _, variable := range environment {
+			builder.WriteString(fmt.Sprintf("$Env:%s = %s\n", variable.Name, quotePowershell(variable.Value)))
+		}
+	case "dotenv":
+		for _, variable := range environment {
+			builder.WriteString(fmt.Sprintf("%s=%s\n", variable.Name, quotePosix(variable.Value)))
+		}
+	case "json":
+		values := make(map[string]string, len(environment))
+		for _, variable := range environment {
+			values[variable.Name] = variable.Value
+		}
+		content, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		builder.Write(content)
+		builder.WriteString("\n")
+	default:
+		return "", unsupportedShellError(shell)
+	}
+
+	return builder.String(), nil
+}
+
+func FormatUnsetEnvironment(shell string, names []string) (string, error) {
+	var builder strings.Builder
+	switch shell {
+	case "bash", "zsh":
+		for _, name := range names {
+			builder.WriteString(fmt.Sprintf("unset %s\n", name))
+		}
+	case "fish":
+		for _, name := range names {
+			builder.WriteString(fmt.Sprintf("set -e %s;\n", name))
+		}
+	case "powershell":
+		for _, name := range names {
+			builder.WriteString(fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue\n", name))
+		}
+	case "dotenv", "json":
+		return "", fmt.Errorf("--unset is not supported for %s output", shell)
+	default:
+		return "", unsupportedShellError(shell)
+	}
+
+	return builder.String(), nil
+}
+
+func unsupportedShellError(shell string) error {
+	return fmt.Errorf("unsupported shell \"%s\", expected one of: %s", shell, strings.Join(Shells, ", "))
+}
+
+func quotePosix(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func quoteFish(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+func quotePowershell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,63 @@
+package internal
+
+import "testing"
+
+func TestFormatEnvironment(t *testing.T) {
+	environment := []EnvironmentVariable{
+		{Name: "AWS_ACCESS_KEY_ID", Value: "ASIAEXAMPLE"},
+		{Name: "AWS_SESSION_TOKEN", Value: `it's a \ token`},
+	}
+
+	tests := []struct {
+		shell   string
+		want    string
+		wantErr bool
+	}{
+		{shell: "bash", want: "export AWS_ACCESS_KEY_ID='ASIAEXAMPLE'\nexport AWS_SESSION_TOKEN='it'\\''s a \\ token'\n"},
+		{shell: "zsh", want: "export AWS_ACCESS_KEY_ID='ASIAEXAMPLE'\nexport AWS_SESSION_TOKEN='it'\\''s a \\ token'\n"},
+		{shell: "fish", want: "set -gx AWS_ACCESS_KEY_ID 'ASIAEXAMPLE';\nset -gx AWS_SESSION_TOKEN 'it\\'s a \\\\ token';\n"},
+		{shell: "powershell", want: "$Env:AWS_ACCESS_KEY_ID = 'ASIAEXAMPLE'\n$Env:AWS_SESSION_TOKEN = 'it''s a \\ token'\n"},
+		{shell: "dotenv", want: "AWS_ACCESS_KEY_ID='ASIAEXAMPLE'\nAWS_SESSION_TOKEN='it'\\''s a \\ token'\n"},
+		{shell: "json", want: "{\n  \"AWS_ACCESS_KEY_ID\": \"ASIAEXAMPLE\",\n  \"AWS_SESSION_TOKEN\": \"it's a \\\\ token\"\n}\n"},
+		{shell: "cmd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			got, err := FormatEnvironment(tt.shell, environment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FormatEnvironment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatUnsetEnvironment(t *testing.T) {
+	names := []string{"AWS_ACCESS_KEY_ID", "AWS_PROFILE"}
+
+	tests := []struct {
+		shell   string
+		want    string
+		wantErr bool
+	}{
+		{shell: "bash", want: "unset AWS_ACCESS_KEY_ID\nunset AWS_PROFILE\n"},
+		{shell: "fish", want: "set -e AWS_ACCESS_KEY_ID;\nset -e AWS_PROFILE;\n"},
+		{shell: "powershell", want: "Remove-Item Env:AWS_ACCESS_KEY_ID -ErrorAction SilentlyContinue\nRemove-Item Env:AWS_PROFILE -ErrorAction SilentlyContinue\n"},
+		{shell: "dotenv", wantErr: true},
+		{shell: "json", wantErr: true},
+		{shell: "cmd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			got, err := FormatUnsetEnvironment(tt.shell, names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatUnsetEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FormatUnsetEnvironment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+type EnvironmentVariable struct {
+	Name  string
+	Value string
+}
+
+var CredentialsEnvironmentVariableNames = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_REGION",
+	"AWS_CREDENTIAL_EXPIRATION",
+}
+
+func CredentialsEnvironment(credentials *ssoTypes.RoleCredentials, region string) []EnvironmentVariable {
+	return []EnvironmentVariable{
+		{Name: "AWS_ACCESS_KEY_ID", Value: *credentials.AccessKeyId},
+		{Name: "AWS_SECRET_ACCESS_KEY", Value: *credentials.SecretAccessKey},
+		{Name: "AWS_SESSION_TOKEN", Value: *credentials.SessionToken},
+		{Name: "AWS_REGION", Value: region},
+		{Name: "AWS_CREDENTIAL_EXPIRATION", Value: formatExpiration(credentials)},
+	}
+}
+
+// RunWithEnvironment runs the command with the given variables added to the current environment.
+// Signals received by awsx are forwarded to the child and its exit code is returned.
+func RunWithEnvironment(command []string, environment []EnvironmentVariable) (int, error) {
+	if len(command) == 0 {
+		return 1, errors.New("no command specified")
+	}
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = os.Environ()
+	for _, variable := range environment {
+		child.Env = append(child.Env, variable.Name+"="+variable.Value)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	if err := child.Start(); err != nil {
+		signal.Stop(signals)
+		return 1, err
+	}
+
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	signal.Stop(signals)
+	close(signals)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Mirror the shell convention for children terminated by a signal
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
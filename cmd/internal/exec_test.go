@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the child run by the RunWithEnvironment tests, not a test of its own.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("AWSX_TEST_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "ASIAEXAMPLE" {
+		os.Exit(2)
+	}
+	if readyFile := os.Getenv("AWSX_TEST_READY_FILE"); readyFile != "" {
+		_ = os.WriteFile(readyFile, nil, 0600)
+		time.Sleep(time.Minute)
+	}
+	exitCode, _ := strconv.Atoi(os.Getenv("AWSX_TEST_EXIT_CODE"))
+	os.Exit(exitCode)
+}
+
+func helperCommand(t *testing.T, environment map[string]string) []string {
+	t.Helper()
+	t.Setenv("AWSX_TEST_HELPER_PROCESS", "1")
+	for name, value := range environment {
+		t.Setenv(name, value)
+	}
+	return []string{os.Args[0], "-test.run=^TestHelperProcess$"}
+}
+
+var testCredentialsEnvironment = []EnvironmentVariable{{Name: "AWS_ACCESS_KEY_ID", Value: "ASIAEXAMPLE"}}
+
+func TestRunWithEnvironmentExitCode(t *testing.T) {
+	for _, want := range []int{0, 1, 3, 42} {
+		t.Run(strconv.Itoa(want), func(t *testing.T) {
+			command := helperCommand(t, map[string]string{"AWSX_TEST_EXIT_CODE": strconv.Itoa(want)})
+			exitCode, err := RunWithEnvironment(command, testCredentialsEnvironment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exitCode != want {
+				t.Errorf("exit code = %d, want %d", exitCode, want)
+			}
+		})
+	}
+}
+
+func TestRunWithEnvironmentPassesVariables(t *testing.T) {
+	command := helperCommand(t, nil)
+	exitCode, err := RunWithEnvironment(command, []EnvironmentVariable{{Name: "AWS_ACCESS_KEY_ID", Value: "other"}})
+	if err != nil || exitCode != 2 {
+		t.Fatalf("RunWithEnvironment() = %d, %v, want the exit code 2 of a child without the credentials", exitCode, err)
+	}
+}
+
+func TestRunWithEnvironmentErrors(t *testing.T) {
+	exitCode, err := RunWithEnvironment(nil, nil)
+	if err == nil || exitCode != 1 {
+		t.Errorf("RunWithEnvironment() without a command = %d, %v, want an error", exitCode, err)
+	}
+
+	exitCode, err = RunWithEnvironment([]string{filepath.Join(t.TempDir(), "missing")}, nil)
+	if err == nil || exitCode != 1 {
+		t.Errorf("RunWithEnvironment() of a missing command = %d, %v, want an error", exitCode, err)
+	}
+}
+
+func TestRunWithEnvironmentForwardsSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to a process on Windows")
+	}
+
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	command := helperCommand(t, map[string]string{"AWSX_TEST_READY_FILE": readyFile})
+
+	type result struct {
+		exitCode int
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		exitCode, err := RunWithEnvironment(command, testCredentialsEnvironment)
+		done <- result{exitCode, err}
+	}()
+
+	// The ready file is written by the child, so awsx already forwards signals
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(readyFile); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the child did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = self.Signal(syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil || r.exitCode != 128+int(syscall.SIGTERM) {
+			t.Fatalf("RunWithEnvironment() = %d, %v, want %d for a child terminated by SIGTERM", r.exitCode, r.err, 128+int(syscall.SIGTERM))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("SIGTERM was not forwarded to the child")
+	}
+}
@@ -0,0 +1,514 @@
+// Package fakesso is an in-process stand-in for the AWS SSO and SSO OIDC APIs. Its clients implement
+// internal.OidcClient and internal.SsoClient, so that selecting, refreshing and the token lifecycle can
+// be exercised without AWS. It doesn't import the internal package, so that its tests can use the fake.
+//
+// Both login flows are covered: device authorizations are answered by polling CreateToken, and the
+// authorization code flow by handing the authorization URL to Server.OpenUrl, which plays the browser.
+package fakesso
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	oidcTypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	deviceCodeGrantType        = "urn:ietf:params:oauth:grant-type:device_code"
+	refreshTokenGrantType      = "refresh_token"
+	authorizationCodeGrantType = "authorization_code"
+)
+
+// Endpoint is the OIDC endpoint of the fake, which authorization URLs of the authorization code flow point to.
+const Endpoint = "https://oidc.fake.invalid"
+
+// AuthState is the answer of the fake user to a device authorization or an authorization request.
+type AuthState int
+
+const (
+	// AuthApproved approves every device authorization after PendingPolls polls and every authorization request
+	AuthApproved AuthState = iota
+	// AuthDenied denies every device authorization and authorization request
+	AuthDenied
+	// AuthExpired lets every device authorization and authorization code expire before it is redeemed
+	AuthExpired
+)
+
+type Account struct {
+	Id    string
+	Name  string
+	Email string
+	Roles []string
+}
+
+// Server holds the state shared by the OIDC and SSO clients of the fake. The exported fields may be changed
+// between calls to script a scenario.
+type Server struct {
+	Accounts []Account
+	// Auth decides how device authorizations and authorization requests end
+	Auth AuthState
+	// PendingPolls is the number of CreateToken polls answered with AuthorizationPendingException before approval
+	PendingPolls int
+	// PollingInterval is returned with device authorizations, in seconds
+	PollingInterval int32
+	// Throttle is the number of following calls that fail with a throttling error
+	Throttle int
+	// PageSize limits the results of a list call, unless the request asks for less
+	PageSize int
+	// IssueRefreshTokens makes CreateToken return refresh tokens
+	IssueRefreshTokens bool
+	AccessTokenTtl     time.Duration
+	RegistrationTtl    time.Duration
+
+	mutex         sync.Mutex
+	sequence      int
+	calls         map[string]int
+	clients       map[string]string
+	deviceCodes   map[string]*deviceAuthorization
+	codes         map[string]*authorizationCode
+	accessTokens  map[string]time.Time
+	refreshTokens map[string]string
+}
+
+type deviceAuthorization struct {
+	clientId  string
+	polls     int
+	expiresAt time.Time
+}
+
+type authorizationCode struct {
+	clientId      string
+	redirectUri   string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// New returns a fake serving the given accounts. Device authorizations are approved on the first poll.
+func New(accounts ...Account) *Server {
+	return &Server{
+		Accounts:           accounts,
+		PollingInterval:    1,
+		PageSize:           100,
+		IssueRefreshTokens: true,
+		AccessTokenTtl:     8 * time.Hour,
+		RegistrationTtl:    90 * 24 * time.Hour,
+		calls:              make(map[string]int),
+		clients:            make(map[string]string),
+		deviceCodes:        make(map[string]*deviceAuthorization),
+		codes:              make(map[string]*authorizationCode),
+		accessTokens:       make(map[string]time.Time),
+		refreshTokens:      make(map[string]string),
+	}
+}
+
+// Calls returns how often the operation was called.
+func (s *Server) Calls(operation string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls[operation]
+}
+
+// RevokeAccessTokens invalidates all access tokens, as if the SSO session had ended. Refresh tokens stay valid.
+func (s *Server) RevokeAccessTokens() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.accessTokens = make(map[string]time.Time)
+}
+
+// RevokeRefreshTokens invalidates all refresh tokens, so that only a new login yields an access token.
+func (s *Server) RevokeRefreshTokens() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.refreshTokens = make(map[string]string)
+}
+
+// OpenUrl plays the browser of the user for a URL that a login opens. An authorization URL of the authorization
+// code flow is answered on its redirect URI with a code or, when Auth is AuthDenied, with an error. Other URLs,
+// like the verification URI of a device authorization, need nothing from the browser.
+func (s *Server) OpenUrl(rawUrl string) error {
+	authorizeUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if authorizeUrl.Scheme+"://"+authorizeUrl.Host != Endpoint || authorizeUrl.Path != "/authorize" {
+		return nil
+	}
+
+	redirectUri, err := s.authorize(authorizeUrl.Query())
+	if err != nil {
+		return err
+	}
+
+	response, err := http.Get(redirectUri)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// authorize answers an authorization request and returns the redirect URI with the response.
+func (s *Server) authorize(query url.Values) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls["Authorize"]++
+	if _, exists := s.clients[query.Get("client_id")]; !exists {
+		return "", fmt.Errorf("unknown client %q", query.Get("client_id"))
+	}
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", fmt.Errorf("invalid authorization request %s", query.Encode())
+	}
+	redirectUri, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectUri.Scheme != "http" || redirectUri.Hostname() != "127.0.0.1" {
+		return "", fmt.Errorf("redirect URI %q is not a loopback URI", query.Get("redirect_uri"))
+	}
+
+	response := url.Values{"state": {query.Get("state")}}
+	if s.Auth == AuthDenied {
+		response.Set("error", "access_denied")
+	} else {
+		code := s.next("code")
+		expiresAt := time.Now().Add(5 * time.Minute)
+		if s.Auth == AuthExpired {
+			expiresAt = time.Now()
+		}
+		s.codes[code] = &authorizationCode{
+			clientId:      query.Get("client_id"),
+			redirectUri:   query.Get("redirect_uri"),
+			codeChallenge: query.Get("code_challenge"),
+			expiresAt:     expiresAt,
+		}
+		response.Set("code", code)
+	}
+	redirectUri.RawQuery = response.Encode()
+	return redirectUri.String(), nil
+}
+
+func (s *Server) Oidc() *OidcClient {
+	return &OidcClient{server: s}
+}
+
+func (s *Server) Sso() *SsoClient {
+	return &SsoClient{server: s}
+}
+
+// call counts the operation and reports whether it is throttled. The mutex must be held.
+func (s *Server) call(operation string) bool {
+	s.calls[operation]++
+	if s.Throttle > 0 {
+		s.Throttle--
+		return true
+	}
+	return false
+}
+
+func (s *Server) next(prefix string) string {
+	s.sequence++
+	return prefix + "-" + strconv.Itoa(s.sequence)
+}
+
+func (s *Server) validAccessToken(accessToken *string) bool {
+	expiresAt, exists := s.accessTokens[aws.ToString(accessToken)]
+	return exists && time.Now().Before(expiresAt)
+}
+
+func (s *Server) account(accountId string) (Account, bool) {
+	for _, account := range s.Accounts {
+		if account.Id == accountId {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// page returns the bounds of the page starting at nextToken and the token of the following page.
+func (s *Server) page(total int, nextToken *string, maxResults *int32) (int, int, *string, error) {
+	start := 0
+	if nextToken != nil {
+		var err error
+		start, err = strconv.Atoi(*nextToken)
+		if err != nil || start < 0 || start > total {
+			return 0, 0, nil, &ssoTypes.InvalidRequestException{Message: aws.String("invalid next token")}
+		}
+	}
+
+	size := s.PageSize
+	if maxResults != nil && int(*maxResults) < size {
+		size = int(*maxResults)
+	}
+
+	end := min(start+size, total)
+	if end == total {
+		return start, end, nil, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end)), nil
+}
+
+type OidcClient struct {
+	server *Server
+}
+
+// Options tells the endpoint of the fake, to which the authorization code flow sends the browser.
+func (c *OidcClient) Options() ssooidc.Options {
+	return ssooidc.Options{
+		Region:             "us-east-1",
+		BaseEndpoint:       aws.String(Endpoint),
+		EndpointResolverV2: ssooidc.NewDefaultEndpointResolverV2(),
+	}
+}
+
+func (c *OidcClient) RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("RegisterClient") {
+		return nil, &oidcTypes.InternalServerException{Error_: aws.String("throttled")}
+	}
+	if aws.ToString(params.ClientName) == "" || aws.ToString(params.ClientType) != "public" {
+		return nil, &oidcTypes.InvalidClientMetadataException{Error_: aws.String("client name and type public are required")}
+	}
+
+	clientId, clientSecret := s.next("client"), s.next("secret")
+	s.clients[clientId] = clientSecret
+
+	now := time.Now()
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String(clientId),
+		ClientSecret:          aws.String(clientSecret),
+		ClientIdIssuedAt:      now.Unix(),
+		ClientSecretExpiresAt: now.Add(s.RegistrationTtl).Unix(),
+	}, nil
+}
+
+func (c *OidcClient) StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("StartDeviceAuthorization") {
+		return nil, &oidcTypes.SlowDownException{Error_: aws.String("throttled")}
+	}
+	if err := s.authenticateClient(params.ClientId, params.ClientSecret); err != nil {
+		return nil, err
+	}
+
+	deviceCode, userCode := s.next("device"), s.next("USER")
+	expiresIn := int32(600)
+	s.deviceCodes[deviceCode] = &deviceAuthorization{
+		clientId:  aws.ToString(params.ClientId),
+		expiresAt: time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}
+
+	verificationUri := aws.ToString(params.StartUrl) + "#/device"
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String(deviceCode),
+		UserCode:                aws.String(userCode),
+		VerificationUri:         aws.String(verificationUri),
+		VerificationUriComplete: aws.String(verificationUri + "?user_code=" + userCode),
+		ExpiresIn:               expiresIn,
+		Interval:                s.PollingInterval,
+	}, nil
+}
+
+func (c *OidcClient) CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("CreateToken") {
+		return nil, &oidcTypes.SlowDownException{Error_: aws.String("throttled")}
+	}
+	if err := s.authenticateClient(params.ClientId, params.ClientSecret); err != nil {
+		return nil, err
+	}
+
+	switch aws.ToString(params.GrantType) {
+	case deviceCodeGrantType:
+		return s.createTokenForDeviceCode(params)
+	case refreshTokenGrantType:
+		clientId, exists := s.refreshTokens[aws.ToString(params.RefreshToken)]
+		if !exists || clientId != aws.ToString(params.ClientId) {
+			return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("invalid refresh token")}
+		}
+		delete(s.refreshTokens, aws.ToString(params.RefreshToken))
+		return s.issueToken(clientId), nil
+	case authorizationCodeGrantType:
+		return s.createTokenForAuthorizationCode(params)
+	default:
+		return nil, &oidcTypes.UnsupportedGrantTypeException{Error_: params.GrantType}
+	}
+}
+
+func (s *Server) createTokenForDeviceCode(params *ssooidc.CreateTokenInput) (*ssooidc.CreateTokenOutput, error) {
+	authorization, exists := s.deviceCodes[aws.ToString(params.DeviceCode)]
+	if !exists || authorization.clientId != aws.ToString(params.ClientId) {
+		return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("invalid device code")}
+	}
+
+	switch {
+	case s.Auth == AuthExpired || time.Now().After(authorization.expiresAt):
+		return nil, &oidcTypes.ExpiredTokenException{Error_: aws.String("the device code expired")}
+	case s.Auth == AuthDenied:
+		return nil, &oidcTypes.AccessDeniedException{Error_: aws.String("the user denied the authorization")}
+	case authorization.polls < s.PendingPolls:
+		authorization.polls++
+		return nil, &oidcTypes.AuthorizationPendingException{Error_: aws.String("authorization pending")}
+	}
+
+	delete(s.deviceCodes, aws.ToString(params.DeviceCode))
+	return s.issueToken(authorization.clientId), nil
+}
+
+func (s *Server) createTokenForAuthorizationCode(params *ssooidc.CreateTokenInput) (*ssooidc.CreateTokenOutput, error) {
+	code, exists := s.codes[aws.ToString(params.Code)]
+	if !exists || code.clientId != aws.ToString(params.ClientId) {
+		return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("invalid authorization code")}
+	}
+	delete(s.codes, aws.ToString(params.Code))
+
+	codeChallenge := sha256.Sum256([]byte(aws.ToString(params.CodeVerifier)))
+	switch {
+	case !time.Now().Before(code.expiresAt):
+		return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("the authorization code expired")}
+	case code.redirectUri != aws.ToString(params.RedirectUri):
+		return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("the redirect URI differs from the authorization request")}
+	case base64.RawURLEncoding.EncodeToString(codeChallenge[:]) != code.codeChallenge:
+		return nil, &oidcTypes.InvalidGrantException{Error_: aws.String("the code verifier doesn't match the code challenge")}
+	}
+
+	return s.issueToken(code.clientId), nil
+}
+
+func (s *Server) issueToken(clientId string) *ssooidc.CreateTokenOutput {
+	accessToken := s.next("access-token")
+	s.accessTokens[accessToken] = time.Now().Add(s.AccessTokenTtl)
+
+	output := &ssooidc.CreateTokenOutput{
+		AccessToken: aws.String(accessToken),
+		TokenType:   aws.String("Bearer"),
+		ExpiresIn:   int32(s.AccessTokenTtl.Seconds()),
+	}
+	if s.IssueRefreshTokens {
+		refreshToken := s.next("refresh-token")
+		s.refreshTokens[refreshToken] = clientId
+		output.RefreshToken = aws.String(refreshToken)
+	}
+	return output
+}
+
+func (s *Server) authenticateClient(clientId *string, clientSecret *string) error {
+	secret, exists := s.clients[aws.ToString(clientId)]
+	if !exists || secret != aws.ToString(clientSecret) {
+		return &oidcTypes.InvalidClientException{Error_: aws.String("unknown client")}
+	}
+	return nil
+}
+
+type SsoClient struct {
+	server *Server
+}
+
+func (c *SsoClient) ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("ListAccounts") {
+		return nil, &ssoTypes.TooManyRequestsException{Message: aws.String("throttled")}
+	}
+	if !s.validAccessToken(params.AccessToken) {
+		return nil, &ssoTypes.UnauthorizedException{Message: aws.String("session token not found or invalid")}
+	}
+
+	start, end, nextToken, err := s.page(len(s.Accounts), params.NextToken, params.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &sso.ListAccountsOutput{NextToken: nextToken}
+	for _, account := range s.Accounts[start:end] {
+		output.AccountList = append(output.AccountList, ssoTypes.AccountInfo{
+			AccountId:    aws.String(account.Id),
+			AccountName:  aws.String(account.Name),
+			EmailAddress: aws.String(account.Email),
+		})
+	}
+	return output, nil
+}
+
+func (c *SsoClient) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("ListAccountRoles") {
+		return nil, &ssoTypes.TooManyRequestsException{Message: aws.String("throttled")}
+	}
+	if !s.validAccessToken(params.AccessToken) {
+		return nil, &ssoTypes.UnauthorizedException{Message: aws.String("session token not found or invalid")}
+	}
+
+	account, exists := s.account(aws.ToString(params.AccountId))
+	if !exists {
+		return nil, &ssoTypes.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("account %s not found", aws.ToString(params.AccountId)))}
+	}
+
+	start, end, nextToken, err := s.page(len(account.Roles), params.NextToken, params.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &sso.ListAccountRolesOutput{NextToken: nextToken}
+	for _, role := range account.Roles[start:end] {
+		output.RoleList = append(output.RoleList, ssoTypes.RoleInfo{
+			AccountId: aws.String(account.Id),
+			RoleName:  aws.String(role),
+		})
+	}
+	return output, nil
+}
+
+func (c *SsoClient) GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+	s := c.server
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.call("GetRoleCredentials") {
+		return nil, &ssoTypes.TooManyRequestsException{Message: aws.String("throttled")}
+	}
+	if !s.validAccessToken(params.AccessToken) {
+		return nil, &ssoTypes.UnauthorizedException{Message: aws.String("session token not found or invalid")}
+	}
+
+	account, exists := s.account(aws.ToString(params.AccountId))
+	if !exists || !slices.Contains(account.Roles, aws.ToString(params.RoleName)) {
+		// Like the real API, which doesn't model this error
+		return nil, &smithy.GenericAPIError{Code: "ForbiddenException", Message: "No access", Fault: smithy.FaultClient}
+	}
+
+	return &sso.GetRoleCredentialsOutput{
+		RoleCredentials: &ssoTypes.RoleCredentials{
+			AccessKeyId:     aws.String(s.next("ASIA")),
+			SecretAccessKey: aws.String(s.next("secret-access-key")),
+			SessionToken:    aws.String(s.next("session-token")),
+			Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+		},
+	}, nil
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	lockTimeout       = 10 * time.Second
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+var ErrLockTimeout = errors.New("timed out waiting for file lock")
+
+// errLockBusy is returned by tryLockFile when another process holds the lock
+var errLockBusy = errors.New("file lock is held by another process")
+
+// withFileLock runs fn while holding the advisory lock of fileName, an OS file lock on a "<fileName>.lock"
+// file next to it. Every awsx process takes the lock before reading a file it is about to rewrite. The
+// operating system releases the lock when its process exits, so a crashed process never leaves a stale lock
+// behind, and the lock file itself is never removed.
+func withFileLock(fileName string, fn func() error) error {
+	lockFileName := fileName + ".lock"
+	err := os.MkdirAll(filepath.Dir(lockFileName), 0700)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := os.OpenFile(lockFileName, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lockFile.Close()
+	}()
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err = tryLockFile(lockFile)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockBusy) {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(lockFileName)
+			return fmt.Errorf("%w: %s is locked by awsx process %s", ErrLockTimeout, fileName, strings.TrimSpace(string(holder)))
+		}
+		time.Sleep(lockRetryInterval)
+	}
+	defer func() {
+		_ = unlockFile(lockFile)
+	}()
+
+	// The pid only tells which process holds the lock in the error message above
+	if lockFile.Truncate(0) == nil {
+		_, _ = lockFile.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	return fn()
+}
+
+// writeFileAtomic replaces fileName with content by writing a temporary file in the same directory and
+// renaming it, so readers such as the AWS CLI never see a partially written file. An existing file keeps
+// its permissions and a symlink is followed to its target.
+func writeFileAtomic(fileName string, content []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(fileName); err == nil {
+		fileName = target
+	}
+	if info, err := os.Stat(fileName); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempFileName := tempFile.Name()
+	defer func() {
+		_ = os.Remove(tempFileName)
+	}()
+
+	_, err = tempFile.Write(content)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tempFileName, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFileName, fileName)
+}
+
+func saveIniFileAtomic(file *ini.File, fileName string) error {
+	var buffer bytes.Buffer
+	_, err := file.WriteTo(&buffer)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fileName, buffer.Bytes(), 0644)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithFileLockIsExclusive(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config")
+
+	var holders, maxHolders atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := withFileLock(fileName, func() error {
+				current := holders.Add(1)
+				for {
+					seen := maxHolders.Load()
+					if current <= seen || maxHolders.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				holders.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders.Load() != 1 {
+		t.Fatalf("lock was held by %d callers at once", maxHolders.Load())
+	}
+}
+
+func TestWithFileLockIgnoresLeftoverLockFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config")
+	// A lock file left behind by a crashed process holds no lock
+	err := os.WriteFile(fileName+".lock", []byte("12345"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	err = withFileLock(fileName, func() error {
+		called = true
+		return nil
+	})
+	if err != nil || !called {
+		t.Fatalf("withFileLock() = %v, called = %v", err, called)
+	}
+}
+
+func TestWriteFileAtomicKeepsPermissions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(fileName, []byte("old"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeFileAtomic(fileName, []byte("new"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(fileName)
+	info, _ := os.Stat(fileName)
+	if string(content) != "new" || info.Mode().Perm() != 0640 {
+		t.Fatalf("got %q with mode %v, want \"new\" with mode 0640", content, info.Mode().Perm())
+	}
+}
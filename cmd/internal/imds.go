@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const imdsTokenHeader = "X-aws-ec2-metadata-token"
+const imdsTokenTtlHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+const imdsMaxTokenTtl = 21600
+const imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+// imdsCredentials is the response format of the EC2 instance metadata security credentials
+type imdsCredentials struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+type imdsTokens struct {
+	mutex  sync.Mutex
+	tokens map[string]time.Time
+}
+
+func (t *imdsTokens) issue(ttl time.Duration) (string, error) {
+	token, err := randomUrlSafeString(44)
+	if err != nil {
+		return "", err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	for existing, expiresAt := range t.tokens {
+		if now.After(expiresAt) {
+			delete(t.tokens, existing)
+		}
+	}
+	t.tokens[token] = now.Add(ttl)
+	return token, nil
+}
+
+func (t *imdsTokens) valid(token string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	expiresAt, exists := t.tokens[token]
+	return exists && time.Now().Before(expiresAt)
+}
+
+// ImdsHandler emulates the IMDSv2 token handshake and the security credentials paths of the EC2 instance
+// metadata service, serving the credentials of the cache under the given role name.
+func ImdsHandler(cache *CredentialsCache, roleName string, region string) http.Handler {
+	tokens := &imdsTokens{tokens: make(map[string]time.Time)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ttl, err := strconv.Atoi(r.Header.Get(imdsTokenTtlHeader))
+		if err != nil || ttl < 1 || ttl > imdsMaxTokenTtl {
+			http.Error(w, "invalid token ttl", http.StatusBadRequest)
+			return
+		}
+
+		token, err := tokens.issue(time.Duration(ttl) * time.Second)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set(imdsTokenTtlHeader, strconv.Itoa(ttl))
+		_, _ = w.Write([]byte(token))
+	})
+
+	mux.HandleFunc("/latest/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !tokens.valid(r.Header.Get(imdsTokenHeader)) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/latest/meta-data/placement/region":
+			_, _ = w.Write([]byte(region))
+		case r.URL.Path == imdsCredentialsPath:
+			_, _ = w.Write([]byte(roleName))
+		case r.URL.Path == imdsCredentialsPath+roleName:
+			credentials, err := cache.Get()
+			if err != nil {
+				log.Printf("Failed to retrieve credentials: %v\n", err)
+				http.Error(w, "failed to retrieve credentials", http.StatusInternalServerError)
+				return
+			}
+
+			writeJson(w, imdsCredentials{
+				Code:            "Success",
+				LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+				Type:            "AWS-HMAC",
+				AccessKeyId:     *credentials.AccessKeyId,
+				SecretAccessKey: *credentials.SecretAccessKey,
+				Token:           *credentials.SessionToken,
+				Expiration:      formatExpiration(credentials),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	return mux
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+func newTestImdsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	cache := &CredentialsCache{credentials: &ssoTypes.RoleCredentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli(),
+	}}
+	server := httptest.NewServer(ImdsHandler(cache, "dev", "eu-west-1"))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func imdsRequest(t *testing.T, server *httptest.Server, method string, path string, headers map[string]string) *http.Response {
+	t.Helper()
+	request, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = response.Body.Close()
+	})
+	return response
+}
+
+func readImdsBody(t *testing.T, response *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func imdsToken(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	response := imdsRequest(t, server, http.MethodPut, "/latest/api/token", map[string]string{imdsTokenTtlHeader: "60"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("token status = %d", response.StatusCode)
+	}
+	return readImdsBody(t, response)
+}
+
+func TestImdsTokenHandshake(t *testing.T) {
+	server := newTestImdsServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		ttl        string
+		wantStatus int
+	}{
+		{name: "token", method: http.MethodPut, ttl: "21600", wantStatus: http.StatusOK},
+		{name: "IMDSv1 style GET", method: http.MethodGet, ttl: "60", wantStatus: http.StatusMethodNotAllowed},
+		{name: "missing ttl", method: http.MethodPut, wantStatus: http.StatusBadRequest},
+		{name: "ttl too long", method: http.MethodPut, ttl: "21601", wantStatus: http.StatusBadRequest},
+		{name: "ttl zero", method: http.MethodPut, ttl: "0", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.ttl != "" {
+				headers[imdsTokenTtlHeader] = tt.ttl
+			}
+			response := imdsRequest(t, server, tt.method, "/latest/api/token", headers)
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && response.Header.Get(imdsTokenTtlHeader) != tt.ttl {
+				t.Fatalf("ttl header = %q, want %q", response.Header.Get(imdsTokenTtlHeader), tt.ttl)
+			}
+		})
+	}
+}
+
+func TestImdsMetadata(t *testing.T) {
+	server := newTestImdsServer(t)
+	token := imdsToken(t, server)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "region", path: "/latest/meta-data/placement/region", token: token, wantStatus: http.StatusOK, wantBody: "eu-west-1"},
+		{name: "role name", path: imdsCredentialsPath, token: token, wantStatus: http.StatusOK, wantBody: "dev"},
+		{name: "other role", path: imdsCredentialsPath + "other", token: token, wantStatus: http.StatusNotFound},
+		{name: "without token", path: imdsCredentialsPath + "dev", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", path: imdsCredentialsPath + "dev", token: "unknown", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.token != "" {
+				headers[imdsTokenHeader] = tt.token
+			}
+			response := imdsRequest(t, server, http.MethodGet, tt.path, headers)
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if body := readImdsBody(t, response); tt.wantBody != "" && body != tt.wantBody {
+				t.Fatalf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestImdsCredentials(t *testing.T) {
+	server := newTestImdsServer(t)
+	response := imdsRequest(t, server, http.MethodGet, imdsCredentialsPath+"dev", map[string]string{imdsTokenHeader: imdsToken(t, server)})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", response.StatusCode)
+	}
+
+	var credentials imdsCredentials
+	if err := json.NewDecoder(response.Body).Decode(&credentials); err != nil {
+		t.Fatal(err)
+	}
+	want := imdsCredentials{
+		Code:            "Success",
+		LastUpdated:     credentials.LastUpdated,
+		Type:            "AWS-HMAC",
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		Token:           "session-token",
+		Expiration:      "2030-01-02T03:04:05Z",
+	}
+	if credentials != want {
+		t.Fatalf("credentials = %+v, want %+v", credentials, want)
+	}
+	if _, err := time.Parse(time.RFC3339, credentials.LastUpdated); err != nil {
+		t.Fatalf("LastUpdated %q is not RFC 3339: %v", credentials.LastUpdated, err)
+	}
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+// matchName reports whether the name matches the pattern, which is either a regular expression
+// enclosed in slashes (e.g. /^prod-.*$/) or a glob (e.g. prod-*).
+func matchName(pattern string, name string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expression, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression %s: %w", pattern, err)
+		}
+		return expression.MatchString(name), nil
+	}
+
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	return matched, nil
+}
+
+// matchAccount finds the single account matching an account ID, an exact account name, or a pattern on the account name.
+func matchAccount(accounts []ssoTypes.AccountInfo, pattern string) (ssoTypes.AccountInfo, error) {
+	var matches []ssoTypes.AccountInfo
+	for _, account := range accounts {
+		if *account.AccountId == pattern || *account.AccountName == pattern {
+			matches = append(matches, account)
+		}
+	}
+
+	if len(matches) == 0 {
+		for _, account := range accounts {
+			matched, err := matchName(pattern, *account.AccountName)
+			if err != nil {
+				return ssoTypes.AccountInfo{}, err
+			}
+			if matched {
+				matches = append(matches, account)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return ssoTypes.AccountInfo{}, fmt.Errorf("no account matches \"%s\"", pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		var names []string
+		for _, account := range matches {
+			names = append(names, fmt.Sprintf("%s (%s)", *account.AccountName, *account.AccountId))
+		}
+		return ssoTypes.AccountInfo{}, fmt.Errorf("account \"%s\" is ambiguous, it matches: %s", pattern, strings.Join(names, ", "))
+	}
+}
+
+// matchRole finds the single role matching an exact role name or a pattern on the role name.
+func matchRole(roles []ssoTypes.RoleInfo, pattern string) (ssoTypes.RoleInfo, error) {
+	var matches []ssoTypes.RoleInfo
+	for _, role := range roles {
+		if *role.RoleName == pattern {
+			return role, nil
+		}
+
+		matched, err := matchName(pattern, *role.RoleName)
+		if err != nil {
+			return ssoTypes.RoleInfo{}, err
+		}
+		if matched {
+			matches = append(matches, role)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return ssoTypes.RoleInfo{}, fmt.Errorf("no role matches \"%s\"", pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		var names []string
+		for _, role := range matches {
+			names = append(names, *role.RoleName)
+		}
+		return ssoTypes.RoleInfo{}, fmt.Errorf("role \"%s\" is ambiguous, it matches: %s", pattern, strings.Join(names, ", "))
+	}
+}
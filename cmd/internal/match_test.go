@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+func TestMatchAccount(t *testing.T) {
+	accounts := []ssoTypes.AccountInfo{
+		{AccountId: aws.String("111111111111"), AccountName: aws.String("dev")},
+		{AccountId: aws.String("222222222222"), AccountName: aws.String("prod-eu")},
+		{AccountId: aws.String("333333333333"), AccountName: aws.String("prod-us")},
+		{AccountId: aws.String("444444444444"), AccountName: aws.String("prod")},
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{name: "id", pattern: "222222222222", want: "222222222222"},
+		{name: "exact name", pattern: "dev", want: "111111111111"},
+		{name: "exact name before glob", pattern: "prod", want: "444444444444"},
+		{name: "glob", pattern: "prod-e*", want: "222222222222"},
+		{name: "regex", pattern: "/-us$/", want: "333333333333"},
+		{name: "ambiguous glob", pattern: "prod-*", wantErr: "ambiguous, it matches: prod-eu (222222222222), prod-us (333333333333)"},
+		{name: "ambiguous regex", pattern: "/^prod/", wantErr: "ambiguous"},
+		{name: "no match", pattern: "staging", wantErr: "no account matches"},
+		{name: "empty", pattern: "", wantErr: "no account matches"},
+		{name: "invalid glob", pattern: "prod-[", wantErr: "invalid pattern"},
+		{name: "invalid regex", pattern: "/prod(/", wantErr: "invalid regular expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := matchAccount(accounts, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("matchAccount() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *account.AccountId != tt.want {
+				t.Errorf("matchAccount() = %s, want %s", *account.AccountId, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRole(t *testing.T) {
+	roles := []ssoTypes.RoleInfo{
+		{RoleName: aws.String("AdministratorAccess")},
+		{RoleName: aws.String("Admin")},
+		{RoleName: aws.String("ReadOnly")},
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{name: "exact name", pattern: "ReadOnly", want: "ReadOnly"},
+		{name: "exact name before glob", pattern: "Admin", want: "Admin"},
+		{name: "glob", pattern: "Read*", want: "ReadOnly"},
+		{name: "regex", pattern: "/Access$/", want: "AdministratorAccess"},
+		{name: "ambiguous", pattern: "Admin*", wantErr: "ambiguous, it matches: AdministratorAccess, Admin"},
+		{name: "no match", pattern: "Billing", wantErr: "no role matches"},
+		{name: "empty", pattern: "", wantErr: "no role matches"},
+		{name: "invalid regex", pattern: "/[/", wantErr: "invalid regular expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := matchRole(roles, tt.pattern)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("matchRole() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *role.RoleName != tt.want {
+				t.Errorf("matchRole() = %s, want %s", *role.RoleName, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	awsxHomeEnvironmentVariable           = "AWSX_HOME"
+	awsConfigFileEnvironmentVariable      = "AWS_CONFIG_FILE"
+	awsCredentialsFileEnvironmentVariable = "AWS_SHARED_CREDENTIALS_FILE"
+)
+
+var home, _ = os.UserHomeDir()
+
+// configDirOverride is set by the global --config-dir flag and takes precedence over AWSX_HOME
+var configDirOverride string
+
+func SetConfigDir(configDir string) {
+	configDirOverride = expandHome(configDir)
+}
+
+// expandHome replaces a leading ~ the way the AWS CLI does for AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE
+func expandHome(value string) string {
+	if value == "~" {
+		return home
+	}
+	if strings.HasPrefix(value, "~/") {
+		return path.Join(home, value[2:])
+	}
+	return value
+}
+
+// environmentPath returns the value of the environment variable with a leading ~ expanded, or "" when unset
+func environmentPath(name string) string {
+	return expandHome(os.Getenv(name))
+}
+
+// internalPathOverride returns the directory set with --config-dir or AWSX_HOME, or "" when neither is set
+func internalPathOverride() string {
+	if configDirOverride != "" {
+		return configDirOverride
+	}
+	return environmentPath(awsxHomeEnvironmentVariable)
+}
+
+func awsPath() string {
+	return path.Join(home, ".aws")
+}
+
+func awsCredentialsFileName() string {
+	if fileName := environmentPath(awsCredentialsFileEnvironmentVariable); fileName != "" {
+		return fileName
+	}
+	return path.Join(awsPath(), "credentials")
+}
+
+func awsConfigFileName() string {
+	if fileName := environmentPath(awsConfigFileEnvironmentVariable); fileName != "" {
+		return fileName
+	}
+	return path.Join(awsPath(), "config")
+}
+
+func awsSsoCachePath() string {
+	return path.Join(awsPath(), "sso", "cache")
+}
+
+// InternalPath returns the directory of the awsx configuration: --config-dir, AWSX_HOME,
+// $XDG_CONFIG_HOME/awsx or ~/.config/awsx, in that order.
+func InternalPath() string {
+	if internalPath := internalPathOverride(); internalPath != "" {
+		return internalPath
+	}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return path.Join(configHome, "awsx")
+	}
+	return path.Join(home, ".config", "awsx")
+}
+
+// CachePath returns the directory of the awsx cache. It is inside the configuration directory
+// unless XDG_CACHE_HOME is set and the configuration directory isn't overridden. A cache that
+// already exists inside the configuration directory keeps being used until $XDG_CACHE_HOME/awsx
+// exists, so that setting XDG_CACHE_HOME doesn't lose the cached tokens of an existing installation.
+func CachePath() string {
+	if internalPath := internalPathOverride(); internalPath != "" {
+		return path.Join(internalPath, "cache")
+	}
+	legacyCachePath := path.Join(InternalPath(), "cache")
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); cacheHome != "" {
+		cachePath := path.Join(cacheHome, "awsx")
+		if !directoryExists(cachePath) && directoryExists(legacyCachePath) {
+			return legacyCachePath
+		}
+		return cachePath
+	}
+	return legacyCachePath
+}
+
+func directoryExists(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+func configFileName() string {
+	return path.Join(InternalPath(), "config")
+}
+
+func clientInformationFileName() string {
+	return path.Join(CachePath(), "access-token")
+}
+
+func lastUsageFileName() string {
+	return path.Join(CachePath(), "last-usage")
+}
+
+func catalogFileName() string {
+	return path.Join(CachePath(), "catalog")
+}
+
+func daemonPidFileName() string {
+	return path.Join(CachePath(), "daemon.pid")
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		awsxHome   bool
+		xdgCache   bool
+		existing   []string
+		wantSubdir string
+	}{
+		{name: "default", wantSubdir: ".config/awsx/cache"},
+		{name: "AWSX_HOME", awsxHome: true, xdgCache: true, wantSubdir: "awsx-home/cache"},
+		{name: "XDG_CACHE_HOME for a new installation", xdgCache: true, wantSubdir: "xdg-cache/awsx"},
+		{name: "XDG_CACHE_HOME with an existing cache", xdgCache: true, existing: []string{".config/awsx/cache"}, wantSubdir: ".config/awsx/cache"},
+		{name: "XDG_CACHE_HOME after moving the cache", xdgCache: true, existing: []string{".config/awsx/cache", "xdg-cache/awsx"}, wantSubdir: "xdg-cache/awsx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempHome(t)
+			t.Setenv(awsxHomeEnvironmentVariable, "")
+			if tt.awsxHome {
+				t.Setenv(awsxHomeEnvironmentVariable, filepath.Join(dir, "awsx-home"))
+			}
+			if tt.xdgCache {
+				t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "xdg-cache"))
+			}
+			for _, existing := range tt.existing {
+				if err := os.MkdirAll(filepath.Join(dir, existing), 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got, want := CachePath(), filepath.Join(dir, tt.wantSubdir); got != want {
+				t.Errorf("CachePath() = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+const authorizationCodeGrantType = "authorization_code"
+const redirectHost = "127.0.0.1"
+const redirectPath = "/oauth/callback"
+const authorizationTimeout = 10 * time.Minute
+
+type authorizationResult struct {
+	code string
+	err  error
+}
+
+// redirectUriWithoutPort is registered with the client; the port of the loopback listener
+// is only added to the redirect URI of each authorization request.
+func redirectUriWithoutPort() string {
+	return "http://" + redirectHost + redirectPath
+}
+
+func retrieveTokenWithAuthorizationCode(ctx context.Context, client OidcClient, info *ClientInformation) (*ClientInformation, error) {
+	codeVerifier, err := randomUrlSafeString(64)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomUrlSafeString(32)
+	if err != nil {
+		return nil, err
+	}
+	codeChallengeSum := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(codeChallengeSum[:])
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(redirectHost, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the local redirect listener: %w", err)
+	}
+	redirectUri := fmt.Sprintf("http://%s%s", listener.Addr().String(), redirectPath)
+
+	results := make(chan authorizationResult, 1)
+	server := &http.Server{Handler: authorizationCallbackHandler(state, results)}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer func() {
+		_ = server.Shutdown(context.Background())
+	}()
+
+	authorizeUrl, err := authorizationUrl(client, info.ClientId, redirectUri, state, codeChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Please authorize your client request: " + authorizeUrl)
+	openUrlInBrowser(authorizeUrl)
+
+	var result authorizationResult
+	select {
+	case result = <-results:
+	case <-time.After(authorizationTimeout):
+		return nil, errors.New("timed out waiting for the authorization in the browser")
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrLoginCancelled, ctx.Err())
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	gtp := authorizationCodeGrantType
+	cto, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     &info.ClientId,
+		ClientSecret: &info.ClientSecret,
+		GrantType:    &gtp,
+		Code:         &result.code,
+		CodeVerifier: &codeVerifier,
+		RedirectUri:  &redirectUri,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info.applyCreateTokenOutput(cto)
+	return info, nil
+}
+
+// authorizationCallbackHandler passes the code or error of the authorization response to results. Requests
+// that don't carry the state of the request, like a stray browser tab or a favicon, are rejected without
+// ending the login, which keeps waiting for the real response.
+func authorizationCallbackHandler(state string, results chan<- authorizationResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirectPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "the authorization response does not match the request", http.StatusBadRequest)
+			return
+		}
+
+		var result authorizationResult
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			result.err = errors.New("the authorization response does not contain a code")
+		default:
+			result.code = query.Get("code")
+		}
+
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			_, _ = fmt.Fprintln(w, "awsx has been authorized. You can close this window now.")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	return mux
+}
+
+func authorizationUrl(client OidcClient, clientId string, redirectUri string, state string, codeChallenge string) (string, error) {
+	endpointOptions, ok := client.(oidcEndpointOptions)
+	if !ok {
+		return "", errors.New("the OIDC client does not support the authorization code flow")
+	}
+
+	options := endpointOptions.Options()
+	endpoint, err := options.EndpointResolverV2.ResolveEndpoint(context.Background(), ssooidc.EndpointParameters{
+		Region:       aws.String(options.Region),
+		Endpoint:     options.BaseEndpoint,
+		UseFIPS:      aws.Bool(options.EndpointOptions.UseFIPSEndpoint == aws.FIPSEndpointStateEnabled),
+		UseDualStack: aws.Bool(options.EndpointOptions.UseDualStackEndpoint == aws.DualStackEndpointStateEnabled),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the OIDC endpoint: %w", err)
+	}
+
+	authorizeUrl := endpoint.URI
+	authorizeUrl.Path = authorizeUrl.Path + "/authorize"
+	authorizeUrl.RawQuery = url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientId},
+		"redirect_uri":          {redirectUri},
+		"state":                 {state},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {codeChallenge},
+		"scopes":                {accountAccessScope},
+	}.Encode()
+
+	return authorizeUrl.String(), nil
+}
+
+func randomUrlSafeString(length int) (string, error) {
+	buffer := make([]byte, length)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer)[:length], nil
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+)
+
+// useFakeBrowser lets the fake answer the URLs that a login opens in the browser.
+func useFakeBrowser(t *testing.T, fake *fakesso.Server) {
+	t.Helper()
+	previousOpenUrlInBrowser := openUrlInBrowser
+	openUrlInBrowser = func(url string) {
+		if err := fake.OpenUrl(url); err != nil {
+			t.Errorf("the fake browser failed to open %s: %v", url, err)
+		}
+	}
+	t.Cleanup(func() {
+		openUrlInBrowser = previousOpenUrlInBrowser
+	})
+}
+
+func TestRandomUrlSafeString(t *testing.T) {
+	// RFC 7636 requires a code verifier of 43 to 128 unreserved characters
+	verifierPattern := regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	for _, length := range []int{32, 43, 64, 128} {
+		value, err := randomUrlSafeString(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(value) != length || !verifierPattern.MatchString(value) {
+			t.Errorf("randomUrlSafeString(%d) = %q", length, value)
+		}
+		other, _ := randomUrlSafeString(length)
+		if value == other {
+			t.Errorf("randomUrlSafeString(%d) returned %q twice", length, value)
+		}
+	}
+}
+
+func TestAuthorizationCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantStatus int
+		wantResult bool
+		wantCode   string
+		wantErr    bool
+	}{
+		{
+			name:       "code",
+			query:      url.Values{"state": {"state"}, "code": {"code"}},
+			wantStatus: http.StatusOK,
+			wantResult: true,
+			wantCode:   "code",
+		},
+		{
+			name:       "denied",
+			query:      url.Values{"state": {"state"}, "error": {"access_denied"}},
+			wantStatus: http.StatusBadRequest,
+			wantResult: true,
+			wantErr:    true,
+		},
+		{
+			name:       "missing code",
+			query:      url.Values{"state": {"state"}},
+			wantStatus: http.StatusBadRequest,
+			wantResult: true,
+			wantErr:    true,
+		},
+		{
+			name:       "other state",
+			query:      url.Values{"state": {"other"}, "code": {"code"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing state",
+			query:      url.Values{"code": {"code"}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make(chan authorizationResult, 1)
+			recorder := httptest.NewRecorder()
+			authorizationCallbackHandler("state", results).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, redirectPath+"?"+tt.query.Encode(), nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			select {
+			case result := <-results:
+				if !tt.wantResult {
+					t.Fatalf("unexpected result %+v", result)
+				}
+				if result.code != tt.wantCode || (result.err != nil) != tt.wantErr {
+					t.Errorf("result = %+v, want code %q and error %v", result, tt.wantCode, tt.wantErr)
+				}
+			default:
+				if tt.wantResult {
+					t.Fatal("no result")
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizationCallbackHandlerKeepsWaitingForState(t *testing.T) {
+	results := make(chan authorizationResult, 1)
+	handler := authorizationCallbackHandler("state", results)
+
+	for _, query := range []string{"state=other&code=stray", "code=stray"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, redirectPath+"?"+query, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("status of %s = %d, want %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, redirectPath+"?state=state&code=code", nil))
+	if result := <-results; result.code != "code" || result.err != nil {
+		t.Errorf("result = %+v, want the code of the matching request", result)
+	}
+}
+
+func TestAuthorizationCodeLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    fakesso.AuthState
+		wantErr func(error) bool
+	}{
+		{name: "approved", auth: fakesso.AuthApproved},
+		{
+			name: "denied",
+			auth: fakesso.AuthDenied,
+			wantErr: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "access_denied")
+			},
+		},
+		{
+			name: "expired code",
+			auth: fakesso.AuthExpired,
+			wantErr: func(err error) bool {
+				var invalidGrant *types.InvalidGrantException
+				return errors.As(err, &invalidGrant)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			fake := fakesso.New()
+			fake.Auth = tt.auth
+			useFakeBrowser(t, fake)
+			config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", LoginFlow: LoginFlowAuthorizationCode}
+
+			clientInformation, err := ProcessClientInformation(config, fake.Oidc())
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("ProcessClientInformation() error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientInformation.AccessToken == "" || clientInformation.RefreshToken == "" || clientInformation.LoginFlow != LoginFlowAuthorizationCode {
+				t.Fatalf("client information = %+v", clientInformation)
+			}
+			if fake.Calls("Authorize") != 1 || fake.Calls("StartDeviceAuthorization") != 0 {
+				t.Fatalf("%d authorization requests and %d device authorizations, want one authorization request",
+					fake.Calls("Authorize"), fake.Calls("StartDeviceAuthorization"))
+			}
+		})
+	}
+}
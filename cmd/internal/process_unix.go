@@ -0,0 +1,37 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"syscall"
+)
+
+var unquotedArgumentPattern = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func terminateProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+// quoteCommandArgument quotes the argument for /bin/sh unless it consists of characters without special meaning
+func quoteCommandArgument(argument string) string {
+	if unquotedArgumentPattern.MatchString(argument) {
+		return argument
+	}
+	return quotePosix(argument)
+}
+
+// shellCommand runs a command line through the shell, so that quoting and pipes work as typed
+func shellCommand(commandLine string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", commandLine)
+}
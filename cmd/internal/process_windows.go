@@ -0,0 +1,40 @@
+//go:build windows
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+func processAlive(pid int) bool {
+	// FindProcess opens a handle to the process on Windows and fails if it does not exist
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
+
+func terminateProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+// quoteCommandArgument quotes the argument for cmd.exe unless it consists of characters without special meaning
+func quoteCommandArgument(argument string) string {
+	if argument != "" && !strings.ContainsAny(argument, " \t\"&|<>^%()") {
+		return argument
+	}
+	return `"` + strings.ReplaceAll(argument, `"`, `\"`) + `"`
+}
+
+// shellCommand runs a command line through cmd.exe, so that quoting and pipes work as typed
+func shellCommand(commandLine string) *exec.Cmd {
+	command := exec.Command("cmd.exe")
+	// The command line is passed on verbatim, as cmd.exe doesn't follow the quoting rules exec applies
+	command.SysProcAttr = &syscall.SysProcAttr{CmdLine: "/C " + commandLine}
+	return command
+}
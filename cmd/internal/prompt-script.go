@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNoAnswer = errors.New("no scripted answer")
+
+// renamedLabels maps former prompt labels to their current label, so that answers files keep working
+var renamedLabels = map[string]string{
+	"Start URL Id": "Start URL or Id",
+}
+
+// ScriptedAnswer answers a prompt by its label. Answer holds the text of a prompt or the chosen item of a
+// select, Answers the chosen items of a multi-select. An empty Answer to a prompt accepts its default.
+type ScriptedAnswer struct {
+	Label   string   `yaml:"label" json:"label"`
+	Answer  string   `yaml:"answer,omitempty" json:"answer,omitempty"`
+	Answers []string `yaml:"answers,omitempty" json:"answers,omitempty"`
+}
+
+// ScriptedPrompter answers prompts from a list of answers instead of the terminal. Every answer is used once,
+// so a label that is asked repeatedly, like the profiles of a config, takes the answers in their order.
+type ScriptedPrompter struct {
+	mutex   sync.Mutex
+	answers []ScriptedAnswer
+}
+
+// NewScriptedPrompter reads a YAML or JSON list of answers. The file name "-" reads them from stdin.
+func NewScriptedPrompter(fileName string) (*ScriptedPrompter, error) {
+	var content []byte
+	var err error
+	if fileName == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []ScriptedAnswer
+	err = yaml.Unmarshal(content, &answers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid answers file %s: %w", fileName, err)
+	}
+
+	return &ScriptedPrompter{answers: answers}, nil
+}
+
+func (p *ScriptedPrompter) next(label string) (ScriptedAnswer, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, answer := range p.answers {
+		if answer.Label == label || renamedLabels[answer.Label] == label {
+			p.answers = slices.Delete(p.answers, i, i+1)
+			return answer, nil
+		}
+	}
+	return ScriptedAnswer{}, fmt.Errorf("%w for %q", ErrNoAnswer, label)
+}
+
+func (p *ScriptedPrompter) Select(label string, toSelect []string, searcher func(input string, index int) bool) (int, string, error) {
+	answer, err := p.next(label)
+	if err != nil {
+		return 0, "", err
+	}
+
+	index, err := findItem(label, toSelect, answer.Answer)
+	if err != nil {
+		return 0, "", err
+	}
+	return index, toSelect[index], nil
+}
+
+func (p *ScriptedPrompter) MultiSelect(label string, toSelect []string, searcher func(input string, index int) bool) ([]int, error) {
+	answer, err := p.next(label)
+	if err != nil {
+		return nil, err
+	}
+
+	values := answer.Answers
+	if len(values) == 0 && answer.Answer != "" {
+		values = []string{answer.Answer}
+	}
+
+	indexes := make([]int, 0, len(values))
+	for _, value := range values {
+		index, err := findItem(label, toSelect, value)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func (p *ScriptedPrompter) Prompt(label string, dfault string) (string, error) {
+	answer, err := p.next(label)
+	if err != nil {
+		return "", err
+	}
+
+	if answer.Answer == "" {
+		return dfault, nil
+	}
+	return answer.Answer, nil
+}
+
+// findItem returns the item equal to the answer or else the only item with a word equal to it, so that answers
+// like an account ID still match when the numbering of the items differs from the recorded session.
+func findItem(label string, toSelect []string, answer string) (int, error) {
+	if index := slices.Index(toSelect, answer); index >= 0 {
+		return index, nil
+	}
+
+	found := -1
+	for i, item := range toSelect {
+		if !slices.Contains(strings.Fields(item), answer) {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("answer %q to %q matches more than one item", answer, label)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("answer %q to %q matches no item", answer, label)
+	}
+	return found, nil
+}
+
+// stableAnswer returns the last word of the selected item, like the account ID or the role name of the account
+// and role selections, when it identifies the item on its own. Unlike the full item it doesn't depend on the
+// numbering of the items, so that the answer replays on another machine.
+func stableAnswer(label string, toSelect []string, index int) string {
+	words := strings.Fields(toSelect[index])
+	if len(words) == 0 {
+		return toSelect[index]
+	}
+
+	lastWord := words[len(words)-1]
+	if found, err := findItem(label, toSelect, lastWord); err == nil && found == index {
+		return lastWord
+	}
+	return toSelect[index]
+}
+
+// RecordingPrompter passes prompts on and writes every answer to a file that ScriptedPrompter can replay.
+// The file is rewritten after each answer, so that an aborted session is recorded up to that point.
+type RecordingPrompter struct {
+	Prompter Prompt
+	FileName string
+
+	mutex   sync.Mutex
+	answers []ScriptedAnswer
+}
+
+func (r *RecordingPrompter) record(answer ScriptedAnswer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.answers = append(r.answers, answer)
+	content, err := yaml.Marshal(r.answers)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.FileName, content, 0600)
+}
+
+func (r *RecordingPrompter) Select(label string, toSelect []string, searcher func(input string, index int) bool) (int, string, error) {
+	index, value, err := r.Prompter.Select(label, toSelect, searcher)
+	if err != nil {
+		return index, value, err
+	}
+	return index, value, r.record(ScriptedAnswer{Label: label, Answer: stableAnswer(label, toSelect, index)})
+}
+
+func (r *RecordingPrompter) MultiSelect(label string, toSelect []string, searcher func(input string, index int) bool) ([]int, error) {
+	indexes, err := r.Prompter.MultiSelect(label, toSelect, searcher)
+	if err != nil {
+		return indexes, err
+	}
+
+	values := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		values = append(values, stableAnswer(label, toSelect, index))
+	}
+	return indexes, r.record(ScriptedAnswer{Label: label, Answers: values})
+}
+
+func (r *RecordingPrompter) Prompt(label string, dfault string) (string, error) {
+	value, err := r.Prompter.Prompt(label, dfault)
+	if err != nil {
+		return value, err
+	}
+	return value, r.record(ScriptedAnswer{Label: label, Answer: value})
+}
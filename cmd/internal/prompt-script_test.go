@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestScriptedPrompter returns a prompter answering from the given answers file content.
+func newTestScriptedPrompter(t *testing.T, content string) *ScriptedPrompter {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "answers.yaml")
+	err := os.WriteFile(fileName, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompter, err := NewScriptedPrompter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prompter
+}
+
+func TestScriptedPrompterRenamedLabel(t *testing.T) {
+	prompter := newTestScriptedPrompter(t, "- label: Start URL Id\n  answer: d-1234567890\n")
+
+	answer, err := prompter.Prompt("Start URL or Id", "")
+	if err != nil || answer != "d-1234567890" {
+		t.Fatalf("Prompt() = %q, %v, want the answer recorded with the former label", answer, err)
+	}
+}
+
+func TestNewScriptedPrompter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		stdin   bool
+		wantErr bool
+	}{
+		{name: "yaml", content: "- label: SSO Region\n  answer: eu-west-1\n"},
+		{name: "json", content: `[{"label": "SSO Region", "answer": "eu-west-1"}]`},
+		{name: "stdin", content: "- label: SSO Region\n  answer: eu-west-1\n", stdin: true},
+		{name: "not a list", content: "label: SSO Region\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "answers")
+			err := os.WriteFile(fileName, []byte(tt.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stdin {
+				stdin, err := os.Open(fileName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				previousStdin := os.Stdin
+				os.Stdin = stdin
+				t.Cleanup(func() {
+					os.Stdin = previousStdin
+					_ = stdin.Close()
+				})
+				fileName = "-"
+			}
+
+			prompter, err := NewScriptedPrompter(fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewScriptedPrompter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			answer, err := prompter.Prompt("SSO Region", "")
+			if err != nil || answer != "eu-west-1" {
+				t.Errorf("Prompt() = %q, %v, want eu-west-1", answer, err)
+			}
+		})
+	}
+
+	_, err := NewScriptedPrompter(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("NewScriptedPrompter() of a missing file = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestFindItem(t *testing.T) {
+	accounts := []string{"#0 dev 111111111111", "#1 prod 222222222222", "#2 prod eu 333333333333"}
+	tests := []struct {
+		name    string
+		answer  string
+		want    int
+		wantErr string
+	}{
+		{name: "exact", answer: "#1 prod 222222222222", want: 1},
+		{name: "word", answer: "333333333333", want: 2},
+		{name: "numbering", answer: "#0", want: 0},
+		{name: "ambiguous", answer: "prod", wantErr: "more than one item"},
+		{name: "part of a word", answer: "111", wantErr: "no item"},
+		{name: "none", answer: "staging", wantErr: "no item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findItem("Select your account", accounts, tt.answer)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("findItem() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("findItem() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptedPrompterUsesAnswersOnceInOrder(t *testing.T) {
+	prompter := newTestScriptedPrompter(t, `
+- label: Profile name to configure
+  answer: dev
+- label: Default Profile Region
+- label: Profile name to configure
+  answer: prod
+- label: Select the profile
+  answers: [dev, prod]
+`)
+
+	for _, want := range []string{"dev", "prod"} {
+		answer, err := prompter.Prompt("Profile name to configure", "default")
+		if err != nil || answer != want {
+			t.Fatalf("Prompt() = %q, %v, want %s", answer, err, want)
+		}
+	}
+	_, err := prompter.Prompt("Profile name to configure", "default")
+	if !errors.Is(err, ErrNoAnswer) {
+		t.Fatalf("Prompt() after the last answer = %v, want ErrNoAnswer", err)
+	}
+
+	answer, err := prompter.Prompt("Default Profile Region", "eu-west-1")
+	if err != nil || answer != "eu-west-1" {
+		t.Fatalf("Prompt() = %q, %v, want the default", answer, err)
+	}
+
+	indexes, err := prompter.MultiSelect("Select the profile", []string{"prod", "staging", "dev"}, nil)
+	if err != nil || !slices.Equal(indexes, []int{2, 0}) {
+		t.Fatalf("MultiSelect() = %v, %v, want [2 0]", indexes, err)
+	}
+
+	_, _, err = prompter.Select("SSO Region", []string{"eu-west-1"}, nil)
+	if !errors.Is(err, ErrNoAnswer) {
+		t.Fatalf("Select() without an answer = %v, want ErrNoAnswer", err)
+	}
+}
+
+func TestRecordingPrompterRecordsStableAnswers(t *testing.T) {
+	label := "Select your account"
+	fileName := filepath.Join(t.TempDir(), "recorded.yaml")
+	recorder := &RecordingPrompter{
+		Prompter: newTestScriptedPrompter(t, `
+- label: Select your account
+  answer: "#1 prod eu 222222222222"
+- label: Select your account
+  answer: "#1 shared x"
+- label: Select the profile
+  answers: [prod]
+`),
+		FileName: fileName,
+	}
+
+	_, _, err := recorder.Select(label, []string{"#0 dev 111111111111", "#1 prod eu 222222222222"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last word of the item is ambiguous, so the full item is recorded
+	_, _, err = recorder.Select(label, []string{"#0 other x", "#1 shared x"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = recorder.MultiSelect("Select the profile", []string{"dev", "prod"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewScriptedPrompter(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"222222222222", "#1 shared x"}; replay.answers[0].Answer != want[0] || replay.answers[1].Answer != want[1] {
+		t.Fatalf("recorded %v, want %v", replay.answers, want)
+	}
+
+	// Another machine numbers the accounts differently
+	index, value, err := replay.Select(label, []string{"#0 audit 333333333333", "#1 dev 111111111111", "#2 prod eu 222222222222"}, nil)
+	if err != nil || index != 2 {
+		t.Fatalf("Select() = %d %q, %v, want the prod account", index, value, err)
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/manifoldco/promptui"
@@ -15,6 +16,23 @@ type Prompt interface {
 
 type Prompter struct{}
 
+var ErrNoInput = errors.New("input required but prompting is disabled by --no-input")
+
+// NoInputPrompter fails every prompt, so that scripts fail instead of waiting for input.
+type NoInputPrompter struct{}
+
+func (receiver NoInputPrompter) Select(label string, toSelect []string, searcher func(input string, index int) bool) (int, string, error) {
+	return 0, "", fmt.Errorf("%w: %s", ErrNoInput, label)
+}
+
+func (receiver NoInputPrompter) MultiSelect(label string, toSelect []string, searcher func(input string, index int) bool) ([]int, error) {
+	return nil, fmt.Errorf("%w: %s", ErrNoInput, label)
+}
+
+func (receiver NoInputPrompter) Prompt(label string, dfault string) (string, error) {
+	return "", fmt.Errorf("%w: %s", ErrNoInput, label)
+}
+
 type multiSelectStruct struct {
 	ID         int
 	IsSelected bool
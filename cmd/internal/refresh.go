@@ -5,100 +5,171 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sso"
-	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/smithy-go"
 )
 
-func Refresh(config *Config, profile *Profile, oidcClient *ssooidc.Client, ssoClient *sso.Client) error {
+var ErrNothingToRefresh = errors.New("nothing to refresh yet")
+
+// Refresh refreshes the credentials of the account and role last used with the profile,
+// and selects them first when the profile has never been selected.
+func (s Selector) Refresh(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) error {
+	err := RefreshWithoutPrompt(config, profile, oidcClient, ssoClient)
+	if errors.Is(err, ErrNothingToRefresh) {
+		log.Printf("Nothing to refresh yet for profile %s in config %s", profile.Name, config.Name)
+		return s.Select(config, profile, oidcClient, ssoClient)
+	}
+	return err
+}
+
+// RefreshWithoutPrompt refreshes the credentials of the profile like Refresh, but returns ErrNothingToRefresh
+// instead of prompting for an account and role when the profile has never been selected.
+func RefreshWithoutPrompt(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) error {
 	log.Printf("Refreshing credentials for profile %s in %s config", profile.Name, config.Name)
-	clientInformation, err := ProcessClientInformation(config.Name, config.GetStartUrl(), oidcClient)
+	if profile.GetOutput() == ProfileOutputSsoSession {
+		return refreshSsoSessionProfile(config, profile, oidcClient)
+	}
+
+	roleCredentials, lui, err := RetrieveCredentials(config, profile, oidcClient, ssoClient)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Using Start URL %s", clientInformation.StartUrl)
+	err = WriteAwsConfigFile(profile.Name, config, roleCredentials)
+	if err != nil {
+		return err
+	}
 
-	var accountId *string
-	var roleName *string
+	_ = SaveUsageInformationForConfig(config.Name, lui)
+
+	log.Printf("Retrieved credentials for account %s [%s] successfully", lui.AccountName, lui.AccountId)
+	log.Printf("Assumed role: %s", lui.Role)
+	log.Printf("Credentials expire at: %s\n", time.Unix(roleCredentials.Expiration/1000, 0))
+	fmt.Println()
+	return nil
+}
 
-	luis, err := GetUsageInformationForConfig(config.Name)
+func refreshSsoSessionProfile(config *Config, profile *Profile, oidcClient OidcClient) error {
+	lui := lastUsageInformation(config.Name, profile)
+	if lui == nil {
+		return ErrNothingToRefresh
+	}
 
-	var lui *UsageInformation
-	for p, info := range luis {
-		if p == profile.Name && len(info) > 0 {
-			lui = &info[0]
-		}
+	clientInformation, err := ProcessClientInformation(config, oidcClient)
+	if err != nil {
+		return err
 	}
 
+	return WriteSsoSessionProfile(config, profile, clientInformation, lui)
+}
+
+// RetrieveCredentials fetches role credentials for the account and role last used with the profile
+// without writing them anywhere. It returns ErrNothingToRefresh when the profile has never been selected.
+func RetrieveCredentials(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) (*ssoTypes.RoleCredentials, *UsageInformation, error) {
+	lui := lastUsageInformation(config.Name, profile)
 	if lui == nil {
-		log.Printf("Nothing to refresh yet for profile %s in config %s", profile.Name, config.Name)
-		return Select(config, profile, oidcClient, ssoClient)
+		return nil, nil, fmt.Errorf("%w for profile %s in config %s, run \"awsx select %s %s\" first", ErrNothingToRefresh, profile.Name, config.Name, config.Name, profile.Name)
 	}
 
+	clientInformation, err := ProcessClientInformation(config, oidcClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Using Start URL %s", clientInformation.StartUrl)
 	log.Printf("Attempting to refresh credentials for account %s with role %s", lui.AccountName, lui.Role)
+
+	roleCredentials, err := getRoleCredentials(config, clientInformation, oidcClient, ssoClient, lui.AccountId, lui.Role)
 	if err != nil {
-		if strings.Contains(err.Error(), "no such file") {
-			log.Println("Nothing to refresh yet.")
-			return Select(config, profile, oidcClient, ssoClient)
+		return nil, nil, unwrapSmithyError(err)
+	}
+
+	roleCredentials, err = assumeRoleChain(config, profile, lui, roleCredentials)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return roleCredentials, lui, nil
+}
+
+// lastUsageInformation returns the account and role last used with the profile. A profile that has never been
+// selected falls back to its default account if that includes a role, so that refresh and credential-process
+// work for it without an interactive selection. It returns nil when neither is known.
+func lastUsageInformation(configName string, profile *Profile) *UsageInformation {
+	luis, _ := GetUsageInformationForConfig(configName)
+	if info, exists := luis[profile.Name]; exists && len(info) > 0 {
+		return &info[0]
+	}
+
+	if profile.DefaultAccount != nil && profile.DefaultAccount.AccountId != "" && profile.DefaultAccount.Role != "" {
+		return &UsageInformation{
+			AccountId:   profile.DefaultAccount.AccountId,
+			AccountName: profile.DefaultAccount.AccountName,
+			Role:        profile.DefaultAccount.Role,
+			Profile:     profile.Name,
 		}
-	} else {
-		accountId = &lui.AccountId
-		roleName = &lui.Role
 	}
 
-	rci := &sso.GetRoleCredentialsInput{AccountId: accountId, RoleName: roleName, AccessToken: &clientInformation.AccessToken}
+	return nil
+}
+
+func getRoleCredentials(config *Config, clientInformation *ClientInformation, oidcClient OidcClient, ssoClient SsoClient, accountId string, roleName string) (*ssoTypes.RoleCredentials, error) {
+	rci := &sso.GetRoleCredentialsInput{AccountId: &accountId, RoleName: &roleName, AccessToken: &clientInformation.AccessToken}
 	roleCredentials, err := ssoClient.GetRoleCredentials(context.Background(), rci)
 	if err != nil {
 		// Retry once on UnauthorizedException by re-authenticating to fetch a fresh access token
-		var e *smithy.GenericAPIError
-		if errors.As(err, &e) && e.ErrorCode() == "UnauthorizedException" {
-			log.Println("Access token invalid or expired. Re-authenticating...")
-			refreshedInfo, rErr := HandleOutdatedAccessToken(config.Name, config.GetStartUrl(), clientInformation, oidcClient)
-			if rErr != nil {
-				return unwrapSmithyError(rErr)
-			}
-			rci.AccessToken = &refreshedInfo.AccessToken
-			roleCredentials, err = ssoClient.GetRoleCredentials(context.Background(), rci)
-			if err != nil {
-				return unwrapSmithyError(err)
-			}
-		} else {
-			return unwrapSmithyError(err)
+		if apiErrorCode(err) != "UnauthorizedException" {
+			return nil, err
 		}
-	}
 
-	err = WriteAwsConfigFile(profile.Name, config, roleCredentials.RoleCredentials)
-	if err != nil {
-		return err
+		refreshedInfo, rErr := renewRejectedAccessToken(config, clientInformation, oidcClient)
+		if rErr != nil {
+			return nil, rErr
+		}
+		rci.AccessToken = &refreshedInfo.AccessToken
+		roleCredentials, err = ssoClient.GetRoleCredentials(context.Background(), rci)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = SaveUsageInformationForConfig(config.Name, lui)
+	return roleCredentials.RoleCredentials, nil
+}
 
-	if accountId == nil || roleName == nil {
-		return errors.New("no account or role found")
+// renewRejectedAccessToken renews an access token that AWS SSO rejected. Concurrent refreshes of the config that were
+// rejected with the same token wait for the first renewal and use its token instead of logging in again.
+func renewRejectedAccessToken(config *Config, rejected *ClientInformation, oidcClient OidcClient) (*ClientInformation, error) {
+	unlock := lockLogin(config.Name)
+	defer unlock()
+
+	current, err := LoadClientInformation(config)
+	if err == nil && current.AccessToken != "" && current.AccessToken != rejected.AccessToken && current.AccessTokenExpiresAt.After(time.Now()) {
+		return current, nil
 	}
 
-	log.Printf("Retrieved credentials for account %s [%s] successfully", lui.AccountName, *accountId)
-	log.Printf("Assumed role: %s", *roleName)
-	log.Printf("Credentials expire at: %s\n", time.Unix(roleCredentials.RoleCredentials.Expiration/1000, 0))
-	fmt.Println()
-	return nil
+	log.Println("Access token invalid or expired. Re-authenticating...")
+	return RenewAccessToken(config, rejected, oidcClient)
 }
 
 func unwrapSmithyError(err error) error {
-	var e *smithy.GenericAPIError
-	if !errors.As(err, &e) {
-		return err
-	}
-
-	switch {
-	case e.ErrorCode() == "ForbiddenException":
+	switch apiErrorCode(err) {
+	case "ForbiddenException":
 		return errors.New("you do not have permission to assume the role. Please check your AWS SSO configuration")
 	default:
 		return err
 	}
 }
+
+// apiErrorCode returns the code of the AWS API error in err, or an empty string for other errors. The SDK
+// returns modeled errors like UnauthorizedException as their own types, not as *smithy.GenericAPIError,
+// so they can only be told apart by the code of smithy.APIError.
+func apiErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	return apiErr.ErrorCode()
+}
@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	oidcTypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/aws/smithy-go"
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+)
+
+func TestApiErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "modeled error", err: &ssoTypes.UnauthorizedException{Message: aws.String("expired")}, want: "UnauthorizedException"},
+		{name: "wrapped modeled error", err: fmt.Errorf("operation error: %w", &ssoTypes.UnauthorizedException{}), want: "UnauthorizedException"},
+		{name: "generic error", err: &smithy.GenericAPIError{Code: "ForbiddenException"}, want: "ForbiddenException"},
+		{name: "other error", err: errors.New("connection refused"), want: ""},
+		{name: "no error", err: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiErrorCode(tt.err); got != tt.want {
+				t.Errorf("apiErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRoleCredentialsRetriesUnauthorized(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1"}
+
+	clientInformation, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SSO session ended although the access token has not expired yet
+	fake.RevokeAccessTokens()
+	createTokenCalls := fake.Calls("CreateToken")
+
+	credentials, err := getRoleCredentials(config, clientInformation, fake.Oidc(), fake.Sso(), "111111111111", "Admin")
+	if err != nil {
+		t.Fatalf("getRoleCredentials() error = %v, want a retry with a renewed access token", err)
+	}
+	if aws.ToString(credentials.AccessKeyId) == "" {
+		t.Fatal("no credentials")
+	}
+	if calls := fake.Calls("GetRoleCredentials"); calls != 2 {
+		t.Fatalf("%d calls to GetRoleCredentials, want the failed one and the retry", calls)
+	}
+	if calls := fake.Calls("CreateToken") - createTokenCalls; calls != 1 {
+		t.Fatalf("%d calls to CreateToken, want one to refresh the access token", calls)
+	}
+}
+
+// selectTestProfile logs in and selects the dev account with the Admin role for the dev profile.
+func selectTestProfile(t *testing.T, fake *fakesso.Server, config *Config) {
+	t.Helper()
+	err := Selector{Prompt: NoInputPrompter{}, Account: "dev", Role: "Admin"}.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expireTestAccessToken lets the cached access token expire, so that the next use has to renew it.
+func expireTestAccessToken(t *testing.T, config *Config) *ClientInformation {
+	t.Helper()
+	clientInformation, err := GetClientInformationForConfig(config.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientInformation.AccessTokenExpiresAt = time.Now().Add(-time.Minute)
+	err = SetClientInformationForConfig(config.Name, clientInformation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientInformation
+}
+
+func TestRefreshRotatesRefreshToken(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+	selectTestProfile(t, fake, config)
+	firstAccessKeyId := readTestCredentials(t, "dev")
+	expired := expireTestAccessToken(t, config)
+
+	err := RefreshWithoutPrompt(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := GetClientInformationForConfig(config.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.AccessToken == expired.AccessToken || renewed.RefreshToken == expired.RefreshToken {
+		t.Fatal("the access and refresh token were not rotated")
+	}
+	if fake.Calls("StartDeviceAuthorization") != 1 {
+		t.Fatal("the refresh started a new login instead of using the refresh token")
+	}
+	if readTestCredentials(t, "dev") == firstAccessKeyId {
+		t.Fatal("the credentials were not refreshed")
+	}
+
+	// The fake, like the service, accepts every refresh token only once
+	var invalidGrant *oidcTypes.InvalidGrantException
+	if _, err := refreshAccessToken(fake.Oidc(), expired); !errors.As(err, &invalidGrant) {
+		t.Fatalf("reusing the previous refresh token: error = %v, want InvalidGrantException", err)
+	}
+}
+
+func TestRefreshAfterRevokedTokens(t *testing.T) {
+	tests := []struct {
+		name                string
+		revokeRefreshTokens bool
+		wantLogins          int
+	}{
+		{name: "refresh token still valid", wantLogins: 1},
+		{name: "refresh token revoked", revokeRefreshTokens: true, wantLogins: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+			config := newTestConfig()
+			selectTestProfile(t, fake, config)
+
+			// The SSO session ended while the cached access token has not expired yet
+			fake.RevokeAccessTokens()
+			if tt.revokeRefreshTokens {
+				fake.RevokeRefreshTokens()
+			}
+
+			err := RefreshWithoutPrompt(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logins := fake.Calls("StartDeviceAuthorization"); logins != tt.wantLogins {
+				t.Fatalf("%d logins, want %d", logins, tt.wantLogins)
+			}
+			if calls := fake.Calls("GetRoleCredentials"); calls != 3 {
+				t.Fatalf("%d calls to GetRoleCredentials, want the selection, the rejected refresh and its retry", calls)
+			}
+		})
+	}
+}
+
+func TestRefreshNeverSelectedProfile(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+
+	err := RefreshWithoutPrompt(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if !errors.Is(err, ErrNothingToRefresh) {
+		t.Fatalf("RefreshWithoutPrompt() error = %v, want ErrNothingToRefresh", err)
+	}
+	if calls := fake.Calls("RegisterClient") + fake.Calls("GetRoleCredentials"); calls != 0 {
+		t.Fatalf("%d calls to the fake, want none for a profile without usage", calls)
+	}
+
+	// Refresh selects the profile instead
+	err = Selector{Prompt: NoInputPrompter{}, Account: "dev", Role: "Admin"}.Refresh(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readTestCredentials(t, "dev") == "" {
+		t.Fatal("no credentials written")
+	}
+}
+
+func TestConcurrentRefreshesLogInOnce(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+	clientInformation, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every refresh is rejected with the same access token and can only renew it with a new login
+	fake.RevokeAccessTokens()
+	fake.RevokeRefreshTokens()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(clientInformation ClientInformation) {
+			defer wg.Done()
+			_, err := getRoleCredentials(config, &clientInformation, fake.Oidc(), fake.Sso(), "111111111111", "Admin")
+			if err != nil {
+				t.Error(err)
+			}
+		}(*clientInformation)
+	}
+	wg.Wait()
+
+	if calls := fake.Calls("GetRoleCredentials"); calls != 10 {
+		t.Fatalf("%d calls to GetRoleCredentials, want every refresh to be rejected and retried", calls)
+	}
+	if logins := fake.Calls("StartDeviceAuthorization"); logins != 2 {
+		t.Fatalf("%d logins, want the first one and one renewal shared by all refreshes", logins)
+	}
+}
+
+func TestLastUsageInformation(t *testing.T) {
+	tests := []struct {
+		name           string
+		usedAccountId  string
+		defaultAccount *UsageInformation
+		wantAccountId  string
+	}{
+		{name: "never used"},
+		{name: "used", usedAccountId: "111111111111", wantAccountId: "111111111111"},
+		{
+			name:           "used with another account than the default",
+			usedAccountId:  "111111111111",
+			defaultAccount: &UsageInformation{AccountId: "222222222222", AccountName: "default", Role: "Admin"},
+			wantAccountId:  "111111111111",
+		},
+		{
+			name:           "default account with role",
+			defaultAccount: &UsageInformation{AccountId: "222222222222", AccountName: "default", Role: "Admin"},
+			wantAccountId:  "222222222222",
+		},
+		{
+			name:           "default account without role",
+			defaultAccount: &UsageInformation{AccountId: "222222222222", AccountName: "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			profile := &Profile{Name: "dev", Region: "eu-west-1", DefaultAccount: tt.defaultAccount}
+			if tt.usedAccountId != "" {
+				err := SaveUsageInformationForConfig("work", &UsageInformation{AccountId: tt.usedAccountId, AccountName: "used", Role: "Developer", Profile: "dev"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			usage := lastUsageInformation("work", profile)
+			if tt.wantAccountId == "" {
+				if usage != nil {
+					t.Fatalf("lastUsageInformation() = %+v, want nil", usage)
+				}
+				return
+			}
+			if usage == nil || usage.AccountId != tt.wantAccountId || usage.Profile != "dev" {
+				t.Fatalf("lastUsageInformation() = %+v, want account %s", usage, tt.wantAccountId)
+			}
+		})
+	}
+}
+
+func TestCredentialProcessWithDefaultAccount(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+	config.Profiles["dev"].DefaultAccount = &UsageInformation{AccountId: "111111111111", AccountName: "dev", Role: "Admin"}
+
+	output, err := CredentialProcess(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var credentials CredentialProcessOutput
+	if err := json.Unmarshal(output, &credentials); err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Version != 1 || credentials.AccessKeyId == "" || credentials.SecretAccessKey == "" || credentials.SessionToken == "" {
+		t.Fatalf("credentials = %s", output)
+	}
+	if _, err := time.Parse(time.RFC3339, credentials.Expiration); err != nil {
+		t.Fatalf("Expiration %q is not RFC 3339: %v", credentials.Expiration, err)
+	}
+	if usage := lastUsageInformation(config.Name, config.Profiles["dev"]); usage == nil || usage.AccountId != "111111111111" {
+		t.Fatalf("usage = %+v, want the default account to be recorded", usage)
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	ssoConfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const RetryModeStandard = string(aws.RetryModeStandard)
+const RetryModeAdaptive = string(aws.RetryModeAdaptive)
+
+var RetryModes = []string{RetryModeStandard, RetryModeAdaptive}
+
+// validateNetworkSettings checks the endpoint, proxy and retry settings of the config.
+func (c *Config) validateNetworkSettings() error {
+	for name, endpoint := range map[string]string{"sso_endpoint": c.SsoEndpoint, "oidc_endpoint": c.OidcEndpoint, "sts_endpoint": c.StsEndpoint, "https_proxy": c.HttpsProxy} {
+		if endpoint == "" {
+			continue
+		}
+		parsed, err := url.Parse(endpoint)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("%s %q of config %s must be a URL", name, endpoint, c.Name)
+		}
+	}
+
+	if c.RetryMode != "" && !slices.Contains(RetryModes, c.RetryMode) {
+		return fmt.Errorf("retry_mode %q of config %s must be one of %s", c.RetryMode, c.Name, strings.Join(RetryModes, ", "))
+	}
+
+	return nil
+}
+
+// loadAwsConfig loads the SDK configuration for the region with the network settings of the config applied.
+func loadAwsConfig(config *Config, region string) (aws.Config, error) {
+	err := config.validateNetworkSettings()
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	options := []func(*ssoConfig.LoadOptions) error{ssoConfig.WithRegion(region)}
+
+	if config.HttpsProxy != "" || config.CaBundle != "" {
+		httpClient, err := newHttpClient(config)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		options = append(options, ssoConfig.WithHTTPClient(httpClient))
+	}
+	if config.UseFips {
+		options = append(options, ssoConfig.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if config.UseDualStack {
+		options = append(options, ssoConfig.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	if config.RetryMode != "" {
+		options = append(options, ssoConfig.WithRetryMode(aws.RetryMode(config.RetryMode)))
+	}
+
+	cfg, err := ssoConfig.LoadDefaultConfig(context.Background(), options...)
+	var profileNotExistError ssoConfig.SharedConfigProfileNotExistError
+	if errors.As(err, &profileNotExistError) {
+		// AWS_PROFILE names a profile that awsx may be about to create, and awsx does not use the profile anyway
+		cfg, err = loadAwsConfigWithoutSharedProfile(region, options)
+	}
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load the AWS SDK configuration for config %s: %w", config.Name, err)
+	}
+
+	return cfg, nil
+}
+
+// loadAwsConfigWithoutSharedProfile builds the SDK configuration from the environment and the options alone,
+// like the SDK does when the default profile does not exist.
+func loadAwsConfigWithoutSharedProfile(region string, options []func(*ssoConfig.LoadOptions) error) (aws.Config, error) {
+	var loadOptions ssoConfig.LoadOptions
+	for _, option := range options {
+		err := option(&loadOptions)
+		if err != nil {
+			return aws.Config{}, err
+		}
+	}
+
+	envConfig, err := ssoConfig.NewEnvConfig()
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	cfg := aws.NewConfig()
+	cfg.Region = region
+	cfg.RetryMode = loadOptions.RetryMode
+	if loadOptions.HTTPClient != nil {
+		cfg.HTTPClient = loadOptions.HTTPClient
+	}
+	cfg.ConfigSources = []interface{}{loadOptions, envConfig}
+	return *cfg, nil
+}
+
+// newHttpClient returns an HTTP client using the proxy of the config and trusting its CA bundle
+// in addition to the system certificates.
+func newHttpClient(config *Config) (*awshttp.BuildableClient, error) {
+	var proxyUrl *url.URL
+	if config.HttpsProxy != "" {
+		var err error
+		proxyUrl, err = url.Parse(config.HttpsProxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rootCAs *x509.CertPool
+	if config.CaBundle != "" {
+		pem, err := os.ReadFile(expandHome(config.CaBundle))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA bundle of config %s: %w", config.Name, err)
+		}
+
+		rootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("the CA bundle %s of config %s contains no PEM certificates", config.CaBundle, config.Name)
+		}
+	}
+
+	return awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		if proxyUrl != nil {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		}
+		if rootCAs != nil {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = rootCAs
+		}
+	}), nil
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+func TestValidateNetworkSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "endpoints", config: Config{SsoEndpoint: "https://sso.example.com", OidcEndpoint: "https://oidc.example.com", StsEndpoint: "http://localhost:4566"}},
+		{name: "proxy", config: Config{HttpsProxy: "http://proxy.example.com:3128"}},
+		{name: "endpoint without scheme", config: Config{SsoEndpoint: "sso.example.com"}, wantErr: true},
+		{name: "endpoint without host", config: Config{OidcEndpoint: "https://"}, wantErr: true},
+		{name: "invalid sts endpoint", config: Config{StsEndpoint: "://sts"}, wantErr: true},
+		{name: "proxy without scheme", config: Config{HttpsProxy: "proxy.example.com:3128"}, wantErr: true},
+		{name: "standard retries", config: Config{RetryMode: RetryModeStandard}},
+		{name: "adaptive retries", config: Config{RetryMode: RetryModeAdaptive}},
+		{name: "unknown retry mode", config: Config{RetryMode: "legacy"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validateNetworkSettings()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNetworkSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHttpClientProxy(t *testing.T) {
+	client, err := newHttpClient(&Config{Name: "work", HttpsProxy: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "https://oidc.eu-west-1.amazonaws.com/token", nil)
+	proxyUrl, err := client.GetTransport().Proxy(request)
+	if err != nil || proxyUrl == nil || proxyUrl.String() != "http://proxy.example.com:3128" {
+		t.Fatalf("proxy = %v, %v", proxyUrl, err)
+	}
+}
+
+func TestNewHttpClientCaBundle(t *testing.T) {
+	useTempHome(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	writeBundle := func(name string, content []byte) string {
+		err := os.WriteFile(filepath.Join(home, name), content, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "~/" + name
+	}
+	serverCa := writeBundle("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	noCertificates := writeBundle("empty.pem", []byte("not a certificate"))
+
+	tests := []struct {
+		name        string
+		caBundle    string
+		wantErr     bool
+		wantTrusted bool
+	}{
+		{name: "system certificates"},
+		{name: "bundle with the server CA", caBundle: serverCa, wantTrusted: true},
+		{name: "missing bundle", caBundle: "~/missing.pem", wantErr: true},
+		{name: "bundle without certificates", caBundle: noCertificates, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newHttpClient(&Config{Name: "work", CaBundle: tt.caBundle})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newHttpClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := client.Do(request)
+			if err == nil {
+				_ = response.Body.Close()
+			}
+			if (err == nil) != tt.wantTrusted {
+				t.Fatalf("request to a server of the bundle CA: error = %v, want trusted %v", err, tt.wantTrusted)
+			}
+		})
+	}
+}
+
+// resolveEndpoints returns the endpoints the OIDC and SSO clients send their requests to.
+func resolveEndpoints(t *testing.T, oidcClient OidcClient, ssoClient SsoClient) (string, string) {
+	t.Helper()
+	oidcOptions := oidcClient.(*ssooidc.Client).Options()
+	oidcEndpoint, err := oidcOptions.EndpointResolverV2.ResolveEndpoint(context.Background(), ssooidc.EndpointParameters{
+		Region:       aws.String(oidcOptions.Region),
+		Endpoint:     oidcOptions.BaseEndpoint,
+		UseFIPS:      aws.Bool(oidcOptions.EndpointOptions.UseFIPSEndpoint == aws.FIPSEndpointStateEnabled),
+		UseDualStack: aws.Bool(oidcOptions.EndpointOptions.UseDualStackEndpoint == aws.DualStackEndpointStateEnabled),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssoOptions := ssoClient.(*sso.Client).Options()
+	ssoEndpoint, err := ssoOptions.EndpointResolverV2.ResolveEndpoint(context.Background(), sso.EndpointParameters{
+		Region:       aws.String(ssoOptions.Region),
+		Endpoint:     ssoOptions.BaseEndpoint,
+		UseFIPS:      aws.Bool(ssoOptions.EndpointOptions.UseFIPSEndpoint == aws.FIPSEndpointStateEnabled),
+		UseDualStack: aws.Bool(ssoOptions.EndpointOptions.UseDualStackEndpoint == aws.DualStackEndpointStateEnabled),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return oidcEndpoint.URI.String(), ssoEndpoint.URI.String()
+}
+
+func TestInitClientsEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		env      map[string]string
+		wantOidc string
+		wantSso  string
+		wantErr  bool
+	}{
+		{
+			name:     "regional",
+			config:   Config{SsoRegion: "eu-west-1"},
+			wantOidc: "https://oidc.eu-west-1.amazonaws.com",
+			wantSso:  "https://portal.sso.eu-west-1.amazonaws.com",
+		},
+		{
+			name:     "GovCloud",
+			config:   Config{SsoRegion: "us-gov-west-1", Partition: PartitionAwsUsGov},
+			wantOidc: "https://oidc.us-gov-west-1.amazonaws.com",
+			wantSso:  "https://portal.sso.us-gov-west-1.amazonaws.com",
+		},
+		{
+			name:     "China",
+			config:   Config{SsoRegion: "cn-north-1"},
+			wantOidc: "https://oidc.cn-north-1.amazonaws.com.cn",
+			wantSso:  "https://portal.sso.cn-north-1.amazonaws.com.cn",
+		},
+		{
+			name:    "region outside the partition",
+			config:  Config{SsoRegion: "eu-west-1", Partition: PartitionAwsCn},
+			wantErr: true,
+		},
+		{
+			name:     "overrides",
+			config:   Config{SsoRegion: "eu-west-1", OidcEndpoint: "https://oidc.example.com", SsoEndpoint: "https://sso.example.com"},
+			wantOidc: "https://oidc.example.com",
+			wantSso:  "https://sso.example.com",
+		},
+		{
+			name:     "environment",
+			config:   Config{SsoRegion: "eu-west-1", SsoEndpoint: "https://sso.example.com"},
+			env:      map[string]string{"AWS_ENDPOINT_URL_SSO_OIDC": "https://oidc.internal", "AWS_ENDPOINT_URL_SSO": "https://sso.internal"},
+			wantOidc: "https://oidc.internal",
+			wantSso:  "https://sso.example.com",
+		},
+		{
+			name:     "fips",
+			config:   Config{SsoRegion: "us-east-1", UseFips: true},
+			wantOidc: "https://oidc-fips.us-east-1.amazonaws.com",
+			wantSso:  "https://portal.sso-fips.us-east-1.amazonaws.com",
+		},
+		{
+			name:     "dual-stack",
+			config:   Config{SsoRegion: "eu-west-1", UseDualStack: true},
+			wantOidc: "https://oidc.eu-west-1.api.aws",
+			wantSso:  "https://portal.sso.eu-west-1.api.aws",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			tt.config.Name = "work"
+			tt.config.Id = "d-1234567890"
+
+			oidcClient, ssoClient, err := InitClients(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitClients() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			oidcEndpoint, ssoEndpoint := resolveEndpoints(t, oidcClient, ssoClient)
+			if oidcEndpoint != tt.wantOidc || ssoEndpoint != tt.wantSso {
+				t.Errorf("endpoints = %s and %s, want %s and %s", oidcEndpoint, ssoEndpoint, tt.wantOidc, tt.wantSso)
+			}
+		})
+	}
+}
+
+func TestLoadAwsConfigRetryMode(t *testing.T) {
+	useTempHome(t)
+	for _, retryMode := range []string{"", RetryModeStandard, RetryModeAdaptive} {
+		cfg, err := loadAwsConfig(&Config{Name: "work", RetryMode: retryMode}, "eu-west-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := aws.RetryMode(retryMode); want != "" && cfg.RetryMode != want {
+			t.Errorf("retry mode = %q, want %q", cfg.RetryMode, want)
+		}
+		if cfg.Region != "eu-west-1" {
+			t.Errorf("region = %q", cfg.Region)
+		}
+	}
+
+	_, err := loadAwsConfig(&Config{Name: "work", RetryMode: "legacy"}, "eu-west-1")
+	if err == nil || !strings.Contains(err.Error(), "retry_mode") {
+		t.Errorf("loadAwsConfig() error = %v, want the invalid retry_mode", err)
+	}
+}
+
+func TestLoadAwsConfigUnknownAwsProfile(t *testing.T) {
+	useTempHome(t)
+	t.Setenv("AWS_PROFILE", "dev")
+	t.Setenv("AWS_ENDPOINT_URL_SSO", "https://sso.example.com")
+
+	cfg, err := loadAwsConfig(&Config{Name: "work", RetryMode: RetryModeAdaptive}, "eu-west-1")
+	if err != nil {
+		t.Fatalf("loadAwsConfig() error = %v", err)
+	}
+	if cfg.Region != "eu-west-1" || cfg.RetryMode != aws.RetryModeAdaptive {
+		t.Errorf("region = %q and retry mode = %q", cfg.Region, cfg.RetryMode)
+	}
+	if endpoint := sso.NewFromConfig(cfg).Options().BaseEndpoint; endpoint == nil || *endpoint != "https://sso.example.com" {
+		t.Errorf("sso endpoint = %v, want AWS_ENDPOINT_URL_SSO", endpoint)
+	}
+
+	oidcClient, ssoClient, err := InitClients(&Config{Name: "work", Id: "d-1234567890", SsoRegion: "us-east-1"})
+	if err != nil {
+		t.Fatalf("InitClients() error = %v", err)
+	}
+	oidcEndpoint, ssoEndpoint := resolveEndpoints(t, oidcClient, ssoClient)
+	if oidcEndpoint != "https://oidc.us-east-1.amazonaws.com" || ssoEndpoint != "https://sso.example.com" {
+		t.Errorf("endpoints = %s and %s", oidcEndpoint, ssoEndpoint)
+	}
+}
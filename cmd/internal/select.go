@@ -1,33 +1,44 @@
 package internal
 
 import (
-	"context"
-	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/sso"
-	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
-	"github.com/aws/smithy-go"
 )
 
-func Select(config *Config, profile *Profile, oidcClient *ssooidc.Client, ssoClient *sso.Client) error {
+// Selector selects the account and role of a profile. Account and Role take precedence over the default account
+// of the profile and are matched by ID, exact name or pattern. Everything else is asked for through the Prompt.
+type Selector struct {
+	Prompt  Prompt
+	Account string
+	Role    string
+	// RefreshCatalog bypasses the cached accounts and roles
+	RefreshCatalog bool
+}
+
+func (s Selector) Select(config *Config, profile *Profile, oidcClient OidcClient, ssoClient SsoClient) error {
 	log.Printf("Getting credentials for profile %s in %s config", profile.Name, config.Name)
-	clientInformation, err := ProcessClientInformation(config.Name, config.GetStartUrl(), oidcClient)
+	clientInformation, err := ProcessClientInformation(config, oidcClient)
 	if err != nil {
 		return err
 	}
 
 	log.Printf("Using Start URL %s", clientInformation.StartUrl)
-	promptSelector := Prompter{}
+	catalog := NewAccountCatalog(config, clientInformation, ssoClient, s.RefreshCatalog)
 
 	var accountId, accountName, roleName *string
-	if profile.DefaultAccount == nil {
-		accountInfo := RetrieveAccountInfo(clientInformation, ssoClient, promptSelector)
+	if profile.DefaultAccount == nil || s.Account != "" {
+		accountInfo, err := RetrieveAccountInfo(catalog, s.Prompt, s.Account)
+		if err != nil {
+			return err
+		}
 		accountName = accountInfo.AccountName
-		roleInfo := RetrieveRoleInfo(accountInfo.AccountId, clientInformation, ssoClient, promptSelector)
+		roleInfo, err := RetrieveRoleInfo(accountInfo.AccountId, catalog, s.Prompt, s.Role)
+		if err != nil {
+			return err
+		}
 
 		accountId = accountInfo.AccountId
 		roleName = roleInfo.RoleName
@@ -35,50 +46,47 @@ func Select(config *Config, profile *Profile, oidcClient *ssooidc.Client, ssoCli
 		accountId = aws.String(profile.DefaultAccount.AccountId)
 		accountName = aws.String(profile.DefaultAccount.AccountName)
 
-		if profile.DefaultAccount.Role == "" {
-			roleInfo := RetrieveRoleInfo(accountId, clientInformation, ssoClient, promptSelector)
+		if profile.DefaultAccount.Role == "" || s.Role != "" {
+			roleInfo, err := RetrieveRoleInfo(accountId, catalog, s.Prompt, s.Role)
+			if err != nil {
+				return err
+			}
 			roleName = roleInfo.RoleName
 		} else {
 			roleName = aws.String(profile.DefaultAccount.Role)
 		}
 	}
 
-	_ = SaveUsageInformationForConfig(config.Name, &UsageInformation{
+	usage := &UsageInformation{
 		AccountId:   *accountId,
 		AccountName: *accountName,
 		Role:        *roleName,
 		Profile:     profile.Name,
-	})
+	}
+	_ = SaveUsageInformationForConfig(config.Name, usage)
+
+	if profile.GetOutput() == ProfileOutputSsoSession {
+		return WriteSsoSessionProfile(config, profile, clientInformation, usage)
+	}
 
-	rci := &sso.GetRoleCredentialsInput{AccountId: accountId, RoleName: roleName, AccessToken: &clientInformation.AccessToken}
-	roleCredentials, err := ssoClient.GetRoleCredentials(context.Background(), rci)
+	roleCredentials, err := getRoleCredentials(config, clientInformation, oidcClient, ssoClient, *accountId, *roleName)
 	if err != nil {
-		// Retry once on UnauthorizedException by re-authenticating
-		var e *smithy.GenericAPIError
-		if errors.As(err, &e) && e.ErrorCode() == "UnauthorizedException" {
-			log.Println("Access token invalid or expired. Re-authenticating...")
-			refreshedInfo, rErr := HandleOutdatedAccessToken(config.Name, config.GetStartUrl(), clientInformation, oidcClient)
-			if rErr != nil {
-				return rErr
-			}
-			rci.AccessToken = &refreshedInfo.AccessToken
-			roleCredentials, err = ssoClient.GetRoleCredentials(context.Background(), rci)
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+		return err
+	}
+
+	roleCredentials, err = assumeRoleChain(config, profile, usage, roleCredentials)
+	if err != nil {
+		return err
 	}
 
-	err = WriteAwsConfigFile(profile.Name, config, roleCredentials.RoleCredentials)
+	err = WriteAwsConfigFile(profile.Name, config, roleCredentials)
 	if err != nil {
 		return err
 	}
 
 	log.Printf("Retrieved credentials for account %s [%s] successfully", *accountName, *accountId)
 	log.Printf("Assumed role: %s", *roleName)
-	log.Printf("Credentials expire at: %s\n", time.Unix(roleCredentials.RoleCredentials.Expiration/1000, 0))
+	log.Printf("Credentials expire at: %s\n", time.Unix(roleCredentials.Expiration/1000, 0))
 	fmt.Println()
 	return nil
 }
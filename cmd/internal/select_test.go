@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+	"gopkg.in/ini.v1"
+)
+
+func newTestConfig() *Config {
+	return &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", Profiles: map[string]*Profile{
+		"dev": {Name: "dev", Region: "eu-west-1"},
+	}}
+}
+
+// readTestCredentials returns the access key ID awsx wrote for the profile to ~/.aws/credentials.
+func readTestCredentials(t *testing.T, profile string) string {
+	t.Helper()
+	credentialsFile, err := ini.Load(awsCredentialsFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := credentialsFile.GetSection(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return section.Key("aws_access_key_id").String()
+}
+
+func TestSelectDeviceLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		auth         fakesso.AuthState
+		pendingPolls int
+		wantErr      error
+	}{
+		{name: "approved", auth: fakesso.AuthApproved},
+		{name: "pending", auth: fakesso.AuthApproved, pendingPolls: 2},
+		{name: "denied", auth: fakesso.AuthDenied, wantErr: ErrAuthorizationDenied},
+		{name: "expired", auth: fakesso.AuthExpired, wantErr: ErrDeviceCodeExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempHome(t)
+			fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+			fake.Auth = tt.auth
+			fake.PendingPolls = tt.pendingPolls
+			config := newTestConfig()
+
+			err := Selector{Prompt: NoInputPrompter{}, Account: "dev", Role: "Admin"}.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Select() error = %v, want %v", err, tt.wantErr)
+				}
+				if fake.Calls("GetRoleCredentials") != 0 {
+					t.Fatal("credentials were retrieved without a login")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if calls := fake.Calls("CreateToken"); calls != tt.pendingPolls+1 {
+				t.Fatalf("%d polls, want %d", calls, tt.pendingPolls+1)
+			}
+			if readTestCredentials(t, "dev") == "" {
+				t.Fatal("no credentials written")
+			}
+			if usage := lastUsageInformation(config.Name, config.Profiles["dev"]); usage == nil || usage.AccountId != "111111111111" || usage.Role != "Admin" {
+				t.Fatalf("usage = %+v", usage)
+			}
+		})
+	}
+}
+
+func TestSelectFollowsPages(t *testing.T) {
+	useTempHome(t)
+	var accounts []fakesso.Account
+	for i := 1; i <= 5; i++ {
+		accounts = append(accounts, fakesso.Account{Id: fmt.Sprintf("%012d", i), Name: fmt.Sprintf("account-%d", i), Roles: []string{"Admin", "Developer", "ReadOnly"}})
+	}
+	fake := fakesso.New(accounts...)
+	fake.PageSize = 2
+	config := newTestConfig()
+
+	err := Selector{Prompt: NoInputPrompter{}, Account: "account-5", Role: "ReadOnly"}.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := fake.Calls("ListAccounts"); calls != 3 {
+		t.Fatalf("%d calls to ListAccounts, want 3 pages of 2 accounts", calls)
+	}
+	if calls := fake.Calls("ListAccountRoles"); calls != 2 {
+		t.Fatalf("%d calls to ListAccountRoles, want 2 pages of 2 roles", calls)
+	}
+	if usage := lastUsageInformation(config.Name, config.Profiles["dev"]); usage.AccountId != "000000000005" || usage.Role != "ReadOnly" {
+		t.Fatalf("usage = %+v, want the account and role of the last pages", usage)
+	}
+}
+
+func TestSelectThrottled(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+	selector := Selector{Prompt: NoInputPrompter{}, Account: "dev", Role: "Admin"}
+
+	_, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The SDK retries throttled calls of real clients; a throttling error that reaches awsx ends the command
+	fake.Throttle = 1
+	err = selector.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	var tooManyRequests *ssoTypes.TooManyRequestsException
+	if !errors.As(err, &tooManyRequests) {
+		t.Fatalf("Select() error = %v, want TooManyRequestsException", err)
+	}
+
+	// The failed listing is not cached, so the next selection lists the accounts again
+	err = selector.Select(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls("ListAccounts"); calls != 2 {
+		t.Fatalf("%d calls to ListAccounts, want the throttled one and the retry", calls)
+	}
+	if readTestCredentials(t, "dev") == "" {
+		t.Fatal("no credentials written")
+	}
+}
+
+func TestRetrieveTokenSlowsDown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the slowed down polling interval")
+	}
+	useTempHome(t)
+	fake := fakesso.New()
+	config := newTestConfig()
+
+	clientInformation, err := registerClient(fake.Oidc(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdao, err := startDeviceAuthorization(context.Background(), fake.Oidc(), clientInformation, config.GetStartUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientInformation.DeviceCode = *sdao.DeviceCode
+
+	fake.Throttle = 1
+	start := time.Now()
+	clientInformation, err = retrieveToken(context.Background(), fake.Oidc(), clientInformation, sdao)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interval := time.Duration(sdao.Interval) * time.Second
+	if elapsed := time.Since(start); elapsed < 2*interval+slowDownIncrement {
+		t.Fatalf("token retrieved after %s, want the interval to grow by %s after SlowDownException", elapsed, slowDownIncrement)
+	}
+	if clientInformation.AccessToken == "" || fake.Calls("CreateToken") != 2 {
+		t.Fatalf("%d polls and access token %q", fake.Calls("CreateToken"), clientInformation.AccessToken)
+	}
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// containerCredentials is the response format of the ECS container credentials endpoint
+type containerCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// ContainerCredentialsHandler serves credentials to clients configured with AWS_CONTAINER_CREDENTIALS_FULL_URI
+// and AWS_CONTAINER_AUTHORIZATION_TOKEN.
+func ContainerCredentialsHandler(cache *CredentialsCache, authorizationToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(authorization), []byte(authorizationToken)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		credentials, err := cache.Get()
+		if err != nil {
+			log.Printf("Failed to retrieve credentials: %v\n", err)
+			http.Error(w, "failed to retrieve credentials", http.StatusInternalServerError)
+			return
+		}
+
+		writeJson(w, containerCredentials{
+			AccessKeyId:     *credentials.AccessKeyId,
+			SecretAccessKey: *credentials.SecretAccessKey,
+			Token:           *credentials.SessionToken,
+			Expiration:      formatExpiration(credentials),
+		})
+	})
+}
+
+// Serve runs the handler on the address until awsx is interrupted. The listening address is passed
+// to ready before the first request is accepted, which allows binding to port 0.
+func Serve(address string, handler http.Handler, ready func(addr net.Addr)) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	ready(listener.Addr())
+	err = server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func writeJson(w http.ResponseWriter, value any) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(content)
+}
+
+func GenerateAuthorizationToken() (string, error) {
+	return randomUrlSafeString(32)
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ssoTypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+)
+
+func containerCredentialsRequest(t *testing.T, handler http.Handler, method string, authorization string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, "/", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestContainerCredentialsHandlerAuthorization(t *testing.T) {
+	cache := &CredentialsCache{credentials: &ssoTypes.RoleCredentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+	}}
+	handler := ContainerCredentialsHandler(cache, "token")
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "authorized", method: http.MethodGet, authorization: "token", wantStatus: http.StatusOK},
+		{name: "missing token", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, authorization: "other", wantStatus: http.StatusForbidden},
+		{name: "token prefix", method: http.MethodGet, authorization: "tok", wantStatus: http.StatusForbidden},
+		{name: "post", method: http.MethodPost, authorization: "token", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := containerCredentialsRequest(t, handler, tt.method, tt.authorization)
+			if response.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", response.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestContainerCredentialsHandlerResponse(t *testing.T) {
+	cache := &CredentialsCache{credentials: &ssoTypes.RoleCredentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli(),
+	}}
+	response := containerCredentialsRequest(t, ContainerCredentialsHandler(cache, "token"), http.MethodGet, "token")
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	var body map[string]string
+	err := json.Unmarshal(response.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"AccessKeyId":     "ASIAEXAMPLE",
+		"SecretAccessKey": "secret",
+		"Token":           "session-token",
+		"Expiration":      "2030-01-02T03:04:05Z",
+	}
+	if len(body) != len(want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %q, want %q", key, body[key], value)
+		}
+	}
+}
+
+func TestContainerCredentialsHandlerRefreshesBeforeExpiry(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New(fakesso.Account{Id: "111111111111", Name: "dev", Roles: []string{"Admin"}})
+	config := newTestConfig()
+	selectTestProfile(t, fake, config)
+
+	cache := NewCredentialsCache(config, config.Profiles["dev"], fake.Oidc(), fake.Sso())
+	handler := ContainerCredentialsHandler(cache, "token")
+	accessKeyId := func() string {
+		t.Helper()
+		response := containerCredentialsRequest(t, handler, http.MethodGet, "token")
+		if response.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", response.Code, response.Body)
+		}
+		var body containerCredentials
+		err := json.Unmarshal(response.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body.AccessKeyId
+	}
+
+	first := accessKeyId()
+	if second := accessKeyId(); second != first {
+		t.Fatalf("credentials valid for an hour were replaced: %s, then %s", first, second)
+	}
+	calls := fake.Calls("GetRoleCredentials")
+
+	// Credentials within the refresh window are replaced before they expire
+	cache.credentials.Expiration = time.Now().Add(credentialsRefreshWindow - time.Minute).UnixMilli()
+	if third := accessKeyId(); third == first {
+		t.Fatal("credentials about to expire were served again")
+	}
+	if got := fake.Calls("GetRoleCredentials"); got != calls+1 {
+		t.Fatalf("%d calls to GetRoleCredentials, want %d", got, calls+1)
+	}
+}
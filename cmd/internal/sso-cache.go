@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"time"
+)
+
+// awsCliToken is the access token cache format of the AWS CLI v2 in ~/.aws/sso/cache
+type awsCliToken struct {
+	StartUrl              string `json:"startUrl"`
+	Region                string `json:"region"`
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	ClientId              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+}
+
+// Older versions of the AWS CLI wrote timestamps with a UTC suffix instead of Z
+var awsCliTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05UTC"}
+
+// LoadClientInformation reads the client information of the config from the token cache it is configured to use.
+func LoadClientInformation(config *Config) (*ClientInformation, error) {
+	if err := checkPlaintextTokenCache(config); err != nil {
+		return nil, err
+	}
+	if config.GetTokenCache() == TokenCacheAwsCli {
+		return readAwsCliToken(config)
+	}
+	return GetClientInformationForConfig(config.Name)
+}
+
+// StoreClientInformation writes the client information of the config to the token cache it is configured to use.
+func StoreClientInformation(config *Config, clientInformation *ClientInformation) error {
+	if err := checkPlaintextTokenCache(config); err != nil {
+		return err
+	}
+	if config.GetTokenCache() == TokenCacheAwsCli {
+		// The AWS CLI looks up the token by the session name for sso-session profiles and by the start URL
+		// for legacy profiles with sso_start_url, so both files are written
+		for _, cacheKey := range []string{config.GetSsoSession(), config.GetStartUrl()} {
+			err := writeAwsCliToken(awsCliTokenFileName(cacheKey), config, clientInformation)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := SetClientInformationForConfig(config.Name, clientInformation)
+	if err != nil || !config.hasSsoSessionProfiles() {
+		return err
+	}
+	// Tools resolving the sso-session profiles read the token of the session, so it follows every renewal
+	return writeAwsCliToken(awsCliTokenFileName(config.GetSsoSession()), config, clientInformation)
+}
+
+func (c *Config) hasSsoSessionProfiles() bool {
+	for _, profile := range c.Profiles {
+		if profile.GetOutput() == ProfileOutputSsoSession {
+			return true
+		}
+	}
+	return false
+}
+
+func awsCliTokenFileName(cacheKey string) string {
+	sum := sha1.Sum([]byte(cacheKey))
+	return path.Join(awsSsoCachePath(), hex.EncodeToString(sum[:])+".json")
+}
+
+// readAwsCliToken reads the token of the sso-session of the config, or else the token a legacy profile with
+// sso_start_url left behind. Tokens of legacy profiles carry no client registration, so the registration
+// only needs to be renewed once the access token expires.
+func readAwsCliToken(config *Config) (*ClientInformation, error) {
+	clientInformation := &ClientInformation{
+		AccessTokenExpiresAt:  time.Now().AddDate(-1, 0, 0),
+		ClientSecretExpiresAt: time.Now().AddDate(-1, 0, 0),
+		StartUrl:              config.GetStartUrl(),
+		LoginFlow:             config.GetLoginFlow(),
+	}
+
+	var token *awsCliToken
+	for _, cacheKey := range []string{config.GetSsoSession(), config.GetStartUrl()} {
+		file, err := os.ReadFile(awsCliTokenFileName(cacheKey))
+		if err != nil {
+			continue
+		}
+
+		token = &awsCliToken{}
+		if json.Unmarshal(file, token) == nil && token.AccessToken != "" {
+			break
+		}
+		token = nil
+	}
+	if token == nil {
+		return clientInformation, nil
+	}
+
+	clientInformation.AccessToken = token.AccessToken
+	clientInformation.RefreshToken = token.RefreshToken
+	clientInformation.ClientId = token.ClientId
+	clientInformation.ClientSecret = token.ClientSecret
+	if expiresAt, err := parseAwsCliTime(token.ExpiresAt); err == nil {
+		clientInformation.AccessTokenExpiresAt = expiresAt
+	}
+	if registrationExpiresAt, err := parseAwsCliTime(token.RegistrationExpiresAt); err == nil {
+		clientInformation.ClientSecretExpiresAt = registrationExpiresAt
+	}
+
+	return clientInformation, nil
+}
+
+func writeAwsCliToken(fileName string, config *Config, clientInformation *ClientInformation) error {
+	err := os.MkdirAll(awsSsoCachePath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(awsCliToken{
+		StartUrl:              config.GetStartUrl(),
+		Region:                config.SsoRegion,
+		AccessToken:           clientInformation.AccessToken,
+		ExpiresAt:             clientInformation.AccessTokenExpiresAt.UTC().Format(time.RFC3339),
+		ClientId:              clientInformation.ClientId,
+		ClientSecret:          clientInformation.ClientSecret,
+		RegistrationExpiresAt: clientInformation.ClientSecretExpiresAt.UTC().Format(time.RFC3339),
+		RefreshToken:          clientInformation.RefreshToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	return withFileLock(fileName, func() error {
+		return writeFileAtomic(fileName, content, 0600)
+	})
+}
+
+func parseAwsCliTime(value string) (time.Time, error) {
+	for _, layout := range awsCliTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, errors.New("unsupported time format")
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gerdou/awsx/cmd/internal/fakesso"
+)
+
+func writeTestAwsCliToken(t *testing.T, cacheKey string, token awsCliToken) {
+	t.Helper()
+	content, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(awsSsoCachePath(), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(awsCliTokenFileName(cacheKey), content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAwsCliTokenCacheSharesLogin(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		config   Config
+		cacheKey string
+		token    awsCliToken
+	}{
+		{
+			name:     "token of the sso-session named after the config",
+			config:   Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", TokenCache: TokenCacheAwsCli},
+			cacheKey: "work",
+			token: awsCliToken{
+				AccessToken: "session-token", ExpiresAt: future, ClientId: "client", ClientSecret: "secret",
+				RegistrationExpiresAt: future, RefreshToken: "refresh",
+			},
+		},
+		{
+			name:     "token of a configured sso-session",
+			config:   Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", TokenCache: TokenCacheAwsCli, SsoSession: "corp"},
+			cacheKey: "corp",
+			token:    awsCliToken{AccessToken: "session-token", ExpiresAt: future, ClientId: "client", ClientSecret: "secret", RegistrationExpiresAt: future},
+		},
+		{
+			name:     "legacy token without client registration",
+			config:   Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", TokenCache: TokenCacheAwsCli},
+			cacheKey: "https://d-1234567890.awsapps.com/start",
+			token:    awsCliToken{AccessToken: "session-token", ExpiresAt: future},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTempHome(t)
+			writeTestAwsCliToken(t, test.cacheKey, test.token)
+			fake := fakesso.New()
+
+			clientInformation, err := ProcessClientInformation(&test.config, fake.Oidc())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientInformation.AccessToken != "session-token" {
+				t.Fatalf("AccessToken = %q, want the token of the AWS CLI", clientInformation.AccessToken)
+			}
+			if calls := fake.Calls("RegisterClient") + fake.Calls("CreateToken"); calls != 0 {
+				t.Fatalf("%d calls to the OIDC API, want none while the access token is valid", calls)
+			}
+		})
+	}
+}
+
+func TestStoreClientInformationWritesSessionAndStartUrlTokens(t *testing.T) {
+	useTempHome(t)
+	config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", TokenCache: TokenCacheAwsCli}
+	err := StoreClientInformation(config, &ClientInformation{
+		AccessToken:           "token",
+		AccessTokenExpiresAt:  time.Now().Add(time.Hour),
+		ClientSecretExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cacheKey := range []string{"work", config.GetStartUrl()} {
+		content, err := os.ReadFile(awsCliTokenFileName(cacheKey))
+		if err != nil {
+			t.Fatalf("no token for %s: %v", cacheKey, err)
+		}
+		token := awsCliToken{}
+		if json.Unmarshal(content, &token) != nil || token.AccessToken != "token" || token.StartUrl != config.GetStartUrl() {
+			t.Fatalf("token for %s = %s", cacheKey, content)
+		}
+	}
+
+	if filepath.Dir(awsCliTokenFileName("work")) != filepath.Join(home, ".aws", "sso", "cache") {
+		t.Fatalf("token written to %s", awsCliTokenFileName("work"))
+	}
+}
+
+func TestRenewalUpdatesSsoSessionToken(t *testing.T) {
+	useTempHome(t)
+	fake := fakesso.New()
+	config := &Config{Name: "work", Id: "d-1234567890", SsoRegion: "eu-west-1", Profiles: map[string]*Profile{
+		"dev": {Region: "eu-west-1", Output: ProfileOutputSsoSession},
+	}}
+
+	clientInformation, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the access token expire so that the next call renews it with the refresh token
+	clientInformation.AccessTokenExpiresAt = time.Now().Add(-time.Minute)
+	err = SetClientInformationForConfig(config.Name, clientInformation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := ProcessClientInformation(config, fake.Oidc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.AccessToken == clientInformation.AccessToken {
+		t.Fatal("the access token was not renewed")
+	}
+
+	content, err := os.ReadFile(awsCliTokenFileName("work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := awsCliToken{}
+	if json.Unmarshal(content, &token) != nil || token.AccessToken != renewed.AccessToken {
+		t.Fatalf("sso-session token = %s, want the renewed access token", content)
+	}
+}
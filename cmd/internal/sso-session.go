@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+
+	"gopkg.in/ini.v1"
+)
+
+// WriteSsoSessionProfile writes the profile as an sso-session profile to ~/.aws/config, so that tools
+// resolve SSO credentials natively instead of reading static credentials from ~/.aws/credentials.
+func WriteSsoSessionProfile(config *Config, profile *Profile, clientInformation *ClientInformation, usage *UsageInformation) error {
+	if len(profile.AssumeRole) > 0 {
+		return fmt.Errorf("assume_role is not supported for profile %s with output %s", profile.Name, ProfileOutputSsoSession)
+	}
+
+	err := checkPlaintextTokenCache(config)
+	if err != nil {
+		return err
+	}
+
+	// Tools resolving an sso-session look up the access token by the session name
+	err = writeAwsCliToken(awsCliTokenFileName(config.GetSsoSession()), config, clientInformation)
+	if err != nil {
+		return err
+	}
+
+	err = updateAwsConfigFile(func(awsConfigFile *ini.File) error {
+		sessionSection := awsConfigFile.Section("sso-session " + config.GetSsoSession())
+		sessionSection.Key("sso_start_url").SetValue(config.GetStartUrl())
+		sessionSection.Key("sso_region").SetValue(config.SsoRegion)
+		sessionSection.Key("sso_registration_scopes").SetValue(accountAccessScope)
+
+		profileSection := awsConfigFile.Section(awsConfigSectionName(profile.Name))
+		profileSection.DeleteKey("credential_process")
+		profileSection.Key("sso_session").SetValue(config.GetSsoSession())
+		profileSection.Key("sso_account_id").SetValue(usage.AccountId)
+		profileSection.Key("sso_role_name").SetValue(usage.Role)
+		profileSection.Key("region").SetValue(profile.Region)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = RemoveProfileFromAwsCredentialsFile(profile.Name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote sso-session profile %s for account %s [%s] with role %s", profile.Name, usage.AccountName, usage.AccountId, usage.Role)
+	return nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gerdou/awsx/utilities"
+)
+
+type ProfileStatus struct {
+	Config                      string     `json:"config" yaml:"config"`
+	Profile                     string     `json:"profile" yaml:"profile"`
+	AccessTokenExpiresAt        *time.Time `json:"access_token_expires_at" yaml:"access_token_expires_at"`
+	AccessTokenRefreshable      bool       `json:"access_token_refreshable" yaml:"access_token_refreshable"`
+	ClientRegistrationExpiresAt *time.Time `json:"client_registration_expires_at" yaml:"client_registration_expires_at"`
+	AccountId                   string     `json:"account_id" yaml:"account_id"`
+	AccountName                 string     `json:"account_name" yaml:"account_name"`
+	Role                        string     `json:"role" yaml:"role"`
+	CredentialsExpireAt         *time.Time `json:"credentials_expire_at" yaml:"credentials_expire_at"`
+	Expired                     bool       `json:"expired" yaml:"expired"`
+}
+
+// CollectStatus gathers the token, registration and credential expiry of every profile in every config.
+func CollectStatus() ([]ProfileStatus, error) {
+	configs, err := ReadInternalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	configNames := utilities.Keys(configs)
+	sort.Strings(configNames)
+
+	now := time.Now()
+	var statuses []ProfileStatus
+	for _, configName := range configNames {
+		config := configs[configName]
+
+		var accessTokenExpiresAt, clientRegistrationExpiresAt *time.Time
+		refreshable := false
+		clientInformation, err := LoadClientInformation(config)
+		if err == nil && clientInformation.ClientId != "" {
+			clientRegistrationExpiresAt = &clientInformation.ClientSecretExpiresAt
+			if clientInformation.AccessToken != "" {
+				accessTokenExpiresAt = &clientInformation.AccessTokenExpiresAt
+			}
+			refreshable = clientInformation.RefreshToken != "" && clientInformation.ClientSecretExpiresAt.After(now)
+		}
+
+		usageInformation, _ := GetUsageInformationForConfig(configName)
+
+		profileNames := utilities.Keys(config.Profiles)
+		sort.Strings(profileNames)
+		for _, profileName := range profileNames {
+			status := ProfileStatus{
+				Config:                      configName,
+				Profile:                     profileName,
+				AccessTokenExpiresAt:        accessTokenExpiresAt,
+				AccessTokenRefreshable:      refreshable,
+				ClientRegistrationExpiresAt: clientRegistrationExpiresAt,
+			}
+
+			if usage, exists := usageInformation[profileName]; exists && len(usage) > 0 {
+				status.AccountId = usage[0].AccountId
+				status.AccountName = usage[0].AccountName
+				status.Role = usage[0].Role
+			}
+
+			if config.Profiles[profileName].GetOutput() == ProfileOutputCredentials {
+				if expiration, err := ReadAwsCredentialsExpiration(profileName); err == nil {
+					status.CredentialsExpireAt = &expiration
+				}
+			}
+
+			status.Expired = status.expired(now)
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
+
+// expired tells whether using the profile needs a new login or new credentials. Missing timestamps don't
+// apply to the profile, e.g. before its first use or for profiles without credentials in the credentials
+// file, and an expired access token only counts when it can't be refreshed. An expired client registration
+// only counts once the access token needs renewal, since a valid access token keeps being used until then.
+func (s ProfileStatus) expired(now time.Time) bool {
+	accessTokenValid := s.AccessTokenExpiresAt != nil && !s.AccessTokenExpiresAt.Before(now)
+	if s.AccessTokenExpiresAt != nil && !accessTokenValid && !s.AccessTokenRefreshable {
+		return true
+	}
+	if s.ClientRegistrationExpiresAt != nil && s.ClientRegistrationExpiresAt.Before(now) && !accessTokenValid {
+		return true
+	}
+	return s.CredentialsExpireAt != nil && s.CredentialsExpireAt.Before(now)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProfileStatusExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		status ProfileStatus
+		want   bool
+	}{
+		{
+			name:   "never used",
+			status: ProfileStatus{},
+			want:   false,
+		},
+		{
+			name:   "valid without credentials, e.g. sso-session or credential_process",
+			status: ProfileStatus{AccessTokenExpiresAt: &future, ClientRegistrationExpiresAt: &future},
+			want:   false,
+		},
+		{
+			name:   "everything valid",
+			status: ProfileStatus{AccessTokenExpiresAt: &future, ClientRegistrationExpiresAt: &future, CredentialsExpireAt: &future},
+			want:   false,
+		},
+		{
+			name:   "access token expired but refreshable",
+			status: ProfileStatus{AccessTokenExpiresAt: &past, AccessTokenRefreshable: true, ClientRegistrationExpiresAt: &future, CredentialsExpireAt: &future},
+			want:   false,
+		},
+		{
+			name:   "access token expired without refresh token",
+			status: ProfileStatus{AccessTokenExpiresAt: &past, ClientRegistrationExpiresAt: &future},
+			want:   true,
+		},
+		{
+			name:   "client registration expired with a valid access token",
+			status: ProfileStatus{AccessTokenExpiresAt: &future, ClientRegistrationExpiresAt: &past, CredentialsExpireAt: &future},
+			want:   false,
+		},
+		{
+			name:   "client registration and access token expired",
+			status: ProfileStatus{AccessTokenExpiresAt: &past, ClientRegistrationExpiresAt: &past},
+			want:   true,
+		},
+		{
+			name:   "client registration expired without an access token",
+			status: ProfileStatus{ClientRegistrationExpiresAt: &past},
+			want:   true,
+		},
+		{
+			name:   "credentials expired",
+			status: ProfileStatus{AccessTokenExpiresAt: &future, ClientRegistrationExpiresAt: &future, CredentialsExpireAt: &past},
+			want:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.status.expired(now); got != test.want {
+				t.Errorf("expired() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultSyncTemplate = "{{.AccountName | slug}}-{{.RoleName}}"
+
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// invalidProfileNameCharacters can't appear in the section name of a profile in ~/.aws/config
+var invalidProfileNameCharacters = regexp.MustCompile(`[\[\]\s\p{Cc}]`)
+
+type SyncOptions struct {
+	// Template generates the profile name from a SyncTemplateData
+	Template string
+	// Include and Exclude are patterns on the account name or ID
+	Include []string
+	Exclude []string
+	// Roles are patterns on the role name, all roles are synced when empty
+	Roles []string
+	// Region of new profiles, the SSO region when empty
+	Region string
+	// Prune removes synced profiles whose account or role no longer exists
+	Prune bool
+}
+
+type SyncTemplateData struct {
+	Config      string
+	AccountId   string
+	AccountName string
+	RoleName    string
+}
+
+type SyncResult struct {
+	Added   []string
+	Skipped []string
+	Stale   []string
+	Pruned  []string
+	// Invalid holds an error for every account and role whose generated profile name is invalid
+	Invalid []error
+}
+
+// syncRole is an account and role of the SSO directory that Sync generates a profile for
+type syncRole struct {
+	AccountId   string
+	AccountName string
+	RoleName    string
+}
+
+// Sync creates a profile for every account and role in the SSO directory. Only profiles created by Sync and
+// left unchanged since are ever updated or pruned, so hand-written and hand-edited profiles are skipped.
+// Pruning only considers accounts and roles in scope of the options, so filtering never removes a profile. The directory is listed
+// first and the profiles are then updated while holding the lock of the configuration file.
+func Sync(config *Config, oidcClient OidcClient, ssoClient SsoClient, options SyncOptions) (*SyncResult, error) {
+	nameTemplate, err := parseSyncTemplate(options.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	clientInformation, err := ProcessClientInformation(config, oidcClient)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := ListAllAccounts(clientInformation, ssoClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var syncRoles []syncRole
+	for _, account := range accounts {
+		included, err := syncIncludesAccount(options, *account.AccountId, *account.AccountName)
+		if err != nil {
+			return nil, err
+		}
+		if !included {
+			continue
+		}
+
+		roles, err := ListAllAccountRoles(*account.AccountId, clientInformation, ssoClient)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, role := range roles {
+			included, err = matchesAny(options.Roles, *role.RoleName, true)
+			if err != nil {
+				return nil, err
+			}
+			if included {
+				syncRoles = append(syncRoles, syncRole{AccountId: *account.AccountId, AccountName: *account.AccountName, RoleName: *role.RoleName})
+			}
+		}
+	}
+
+	var result *SyncResult
+	err = UpdateInternalConfig(func(configs map[string]*Config) error {
+		current, exists := configs[config.Name]
+		if !exists {
+			return fmt.Errorf("config %s was removed while syncing it", config.Name)
+		}
+
+		result, err = applySync(current, syncRoles, nameTemplate, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Synced %d accounts for config %s", len(accounts), config.Name)
+	return result, nil
+}
+
+func parseSyncTemplate(text string) (*template.Template, error) {
+	nameTemplate, err := template.New("profile").Funcs(template.FuncMap{
+		"slug":  slug,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile name template: %w", err)
+	}
+	return nameTemplate, nil
+}
+
+func applySync(config *Config, syncRoles []syncRole, nameTemplate *template.Template, options SyncOptions) (*SyncResult, error) {
+	region := options.Region
+	if region == "" {
+		region = config.SsoRegion
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]*Profile)
+	}
+
+	result := &SyncResult{}
+	current := make(map[string]bool)
+	for _, role := range syncRoles {
+		var name bytes.Buffer
+		err := nameTemplate.Execute(&name, SyncTemplateData{
+			Config:      config.Name,
+			AccountId:   role.AccountId,
+			AccountName: role.AccountName,
+			RoleName:    role.RoleName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate profile name: %w", err)
+		}
+		profileName := name.String()
+		err = validateSyncProfileName(profileName)
+		if err != nil {
+			result.Invalid = append(result.Invalid, fmt.Errorf("skipped role %s of account %s (%s): %w", role.RoleName, role.AccountName, role.AccountId, err))
+			continue
+		}
+		if current[profileName] {
+			return nil, fmt.Errorf("the template generates the profile name %s more than once, include .AccountId or .RoleName in it", profileName)
+		}
+		current[profileName] = true
+
+		defaultAccount := &UsageInformation{
+			AccountId:   role.AccountId,
+			AccountName: role.AccountName,
+			Role:        role.RoleName,
+			Profile:     profileName,
+		}
+
+		profile, exists := config.Profiles[profileName]
+		switch {
+		case !exists:
+			profile = &Profile{
+				Region:         region,
+				DefaultAccount: defaultAccount,
+				Synced:         true,
+				Name:           profileName,
+			}
+			profile.SyncHash = syncHash(profile)
+			config.Profiles[profileName] = profile
+			result.Added = append(result.Added, profileName)
+		case !profile.Synced || syncEdited(profile):
+			result.Skipped = append(result.Skipped, profileName)
+		default:
+			profile.DefaultAccount = defaultAccount
+			profile.SyncHash = syncHash(profile)
+		}
+	}
+
+	listed := make(map[syncRole]bool)
+	for _, role := range syncRoles {
+		listed[syncRole{AccountId: role.AccountId, RoleName: role.RoleName}] = true
+	}
+
+	for profileName, profile := range config.Profiles {
+		if !profile.Synced || current[profileName] || profile.DefaultAccount == nil {
+			continue
+		}
+
+		inScope, err := syncIncludesAccount(options, profile.DefaultAccount.AccountId, profile.DefaultAccount.AccountName)
+		if err == nil && inScope {
+			inScope, err = matchesAny(options.Roles, profile.DefaultAccount.Role, true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !inScope || listed[syncRole{AccountId: profile.DefaultAccount.AccountId, RoleName: profile.DefaultAccount.Role}] {
+			continue
+		}
+
+		switch {
+		case syncEdited(profile):
+			result.Skipped = append(result.Skipped, profileName)
+		case options.Prune:
+			delete(config.Profiles, profileName)
+			result.Pruned = append(result.Pruned, profileName)
+		default:
+			result.Stale = append(result.Stale, profileName)
+		}
+	}
+
+	for _, names := range [][]string{result.Added, result.Skipped, result.Stale, result.Pruned} {
+		sort.Strings(names)
+	}
+	return result, nil
+}
+
+// syncHash fingerprints a profile as generated by Sync, so that later runs can tell whether it was edited.
+func syncHash(profile *Profile) string {
+	generated := *profile
+	generated.SyncHash = ""
+	content, _ := yaml.Marshal(generated)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16])
+}
+
+// syncEdited tells whether a synced profile was changed since Sync generated it.
+func syncEdited(profile *Profile) bool {
+	return profile.SyncHash != syncHash(profile)
+}
+
+func validateSyncProfileName(profileName string) error {
+	if profileName == "" {
+		return fmt.Errorf("the template generates an empty profile name")
+	}
+	if invalidProfileNameCharacters.MatchString(profileName) {
+		return fmt.Errorf("the template generates the profile name %q, which contains brackets, whitespace or control characters", profileName)
+	}
+	return nil
+}
+
+func syncIncludesAccount(options SyncOptions, accountId string, accountName string) (bool, error) {
+	if !slices.Contains(options.Include, accountId) {
+		included, err := matchesAny(options.Include, accountName, true)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+
+	if slices.Contains(options.Exclude, accountId) {
+		return false, nil
+	}
+	excluded, err := matchesAny(options.Exclude, accountName, false)
+	return !excluded, err
+}
+
+func matchesAny(patterns []string, name string, whenEmpty bool) (bool, error) {
+	if len(patterns) == 0 {
+		return whenEmpty, nil
+	}
+
+	for _, pattern := range patterns {
+		matched, err := matchName(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func slug(value string) string {
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func syncedProfile(name string, accountId string, accountName string, role string) *Profile {
+	profile := &Profile{
+		Region:         "eu-west-1",
+		DefaultAccount: &UsageInformation{AccountId: accountId, AccountName: accountName, Role: role, Profile: name},
+		Synced:         true,
+		Name:           name,
+	}
+	profile.SyncHash = syncHash(profile)
+	return profile
+}
+
+func TestApplySync(t *testing.T) {
+	directory := []syncRole{
+		{AccountId: "111111111111", AccountName: "Dev", RoleName: "Admin"},
+		{AccountId: "222222222222", AccountName: "Prod", RoleName: "Admin"},
+	}
+
+	tests := []struct {
+		name      string
+		profiles  map[string]*Profile
+		directory []syncRole
+		options   SyncOptions
+		want      SyncResult
+		remaining []string
+	}{
+		{
+			name:      "adds new profiles",
+			profiles:  map[string]*Profile{},
+			directory: directory,
+			want:      SyncResult{Added: []string{"dev-Admin", "prod-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name:      "skips hand-written profiles",
+			profiles:  map[string]*Profile{"dev-Admin": {Region: "us-east-1"}},
+			directory: directory,
+			want:      SyncResult{Added: []string{"prod-Admin"}, Skipped: []string{"dev-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name: "skips hand-edited synced profiles",
+			profiles: map[string]*Profile{"dev-Admin": func() *Profile {
+				profile := syncedProfile("dev-Admin", "111111111111", "Dev", "Admin")
+				profile.Region = "us-east-1"
+				return profile
+			}()},
+			directory: directory,
+			want:      SyncResult{Added: []string{"prod-Admin"}, Skipped: []string{"dev-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name: "skips synced profiles without a hash",
+			profiles: map[string]*Profile{"dev-Admin": func() *Profile {
+				profile := syncedProfile("dev-Admin", "111111111111", "Dev", "Admin")
+				profile.SyncHash = ""
+				return profile
+			}()},
+			directory: directory,
+			want:      SyncResult{Added: []string{"prod-Admin"}, Skipped: []string{"dev-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name:      "prunes profiles of removed accounts",
+			profiles:  map[string]*Profile{"test-Admin": syncedProfile("test-Admin", "333333333333", "Test", "Admin")},
+			directory: directory,
+			options:   SyncOptions{Prune: true},
+			want:      SyncResult{Added: []string{"dev-Admin", "prod-Admin"}, Pruned: []string{"test-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name:      "flags profiles of removed roles without prune",
+			profiles:  map[string]*Profile{"dev-ReadOnly": syncedProfile("dev-ReadOnly", "111111111111", "Dev", "ReadOnly")},
+			directory: directory,
+			want:      SyncResult{Added: []string{"dev-Admin", "prod-Admin"}, Stale: []string{"dev-ReadOnly"}},
+			remaining: []string{"dev-Admin", "dev-ReadOnly", "prod-Admin"},
+		},
+		{
+			name:      "keeps profiles of accounts filtered out with include",
+			profiles:  map[string]*Profile{"prod-Admin": syncedProfile("prod-Admin", "222222222222", "Prod", "Admin")},
+			directory: directory[:1],
+			options:   SyncOptions{Prune: true, Include: []string{"Dev"}},
+			want:      SyncResult{Added: []string{"dev-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name:      "keeps profiles of accounts filtered out with exclude",
+			profiles:  map[string]*Profile{"prod-Admin": syncedProfile("prod-Admin", "222222222222", "Prod", "Admin")},
+			directory: directory[:1],
+			options:   SyncOptions{Prune: true, Exclude: []string{"222222222222"}},
+			want:      SyncResult{Added: []string{"dev-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin"},
+		},
+		{
+			name:      "keeps profiles of roles filtered out",
+			profiles:  map[string]*Profile{"dev-ReadOnly": syncedProfile("dev-ReadOnly", "111111111111", "Dev", "ReadOnly")},
+			directory: directory,
+			options:   SyncOptions{Prune: true, Roles: []string{"Admin"}},
+			want:      SyncResult{Added: []string{"dev-Admin", "prod-Admin"}},
+			remaining: []string{"dev-Admin", "dev-ReadOnly", "prod-Admin"},
+		},
+		{
+			name: "never prunes hand-edited profiles",
+			profiles: map[string]*Profile{"test-Admin": func() *Profile {
+				profile := syncedProfile("test-Admin", "333333333333", "Test", "Admin")
+				profile.Output = ProfileOutputSsoSession
+				return profile
+			}()},
+			directory: directory,
+			options:   SyncOptions{Prune: true},
+			want:      SyncResult{Added: []string{"dev-Admin", "prod-Admin"}, Skipped: []string{"test-Admin"}},
+			remaining: []string{"dev-Admin", "prod-Admin", "test-Admin"},
+		},
+	}
+
+	nameTemplate, err := parseSyncTemplate(DefaultSyncTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Name: "default", SsoRegion: "eu-west-1", Profiles: test.profiles}
+			result, err := applySync(config, test.directory, nameTemplate, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"Added", result.Added, test.want.Added},
+				{"Skipped", result.Skipped, test.want.Skipped},
+				{"Stale", result.Stale, test.want.Stale},
+				{"Pruned", result.Pruned, test.want.Pruned},
+			} {
+				if !slices.Equal(field.got, field.want) {
+					t.Errorf("%s = %v, want %v", field.name, field.got, field.want)
+				}
+			}
+
+			remaining := make([]string, 0, len(config.Profiles))
+			for name := range config.Profiles {
+				remaining = append(remaining, name)
+			}
+			slices.Sort(remaining)
+			if !slices.Equal(remaining, test.remaining) {
+				t.Errorf("profiles = %v, want %v", remaining, test.remaining)
+			}
+		})
+	}
+}
+
+func TestApplySyncUpdatesUneditedProfiles(t *testing.T) {
+	nameTemplate, err := parseSyncTemplate(DefaultSyncTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := syncedProfile("dev-Admin", "111111111111", "Development", "Admin")
+	config := &Config{Name: "default", SsoRegion: "eu-west-1", Profiles: map[string]*Profile{"dev-Admin": profile}}
+	_, err = applySync(config, []syncRole{{AccountId: "111111111111", AccountName: "Dev", RoleName: "Admin"}}, nameTemplate, SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.DefaultAccount.AccountName != "Dev" || syncEdited(profile) {
+		t.Fatalf("profile = %+v, want the renamed account and a matching hash", profile.DefaultAccount)
+	}
+}
+
+func TestApplySyncSkipsInvalidProfileNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{name: "empty", template: `{{if eq .AccountName "Prod"}}{{.AccountName}}{{end}}`},
+		{name: "whitespace", template: "{{.AccountName}}-{{.RoleName}}"},
+		{name: "bracket", template: `{{.AccountName | slug}}{{if eq .AccountId "111111111111"}}]{{end}}`},
+		{name: "newline", template: `{{.AccountName | slug}}{{if eq .AccountId "111111111111"}}{{"\n"}}{{end}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nameTemplate, err := parseSyncTemplate(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := &Config{Name: "default", SsoRegion: "eu-west-1"}
+			directory := []syncRole{
+				{AccountId: "111111111111", AccountName: "Dev Team", RoleName: "Admin"},
+				{AccountId: "222222222222", AccountName: "Prod", RoleName: "Admin"},
+			}
+			result, err := applySync(config, directory, nameTemplate, SyncOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Invalid) != 1 || !strings.Contains(result.Invalid[0].Error(), "111111111111") {
+				t.Errorf("Invalid = %v, want one error for the account 111111111111", result.Invalid)
+			}
+			if len(result.Added) != 1 || len(config.Profiles) != 1 {
+				t.Errorf("Added = %v and profiles = %v, want only the profile of Prod", result.Added, config.Profiles)
+			}
+		})
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/gerdou/awsx/utilities"
 )
 
+var refreshParallel int
+var refreshFailFast bool
+
 var refreshCmd = &cobra.Command{
 	Use:               "refresh",
 	Short:             "Refreshes your previously used credentials.",
@@ -17,20 +20,36 @@ var refreshCmd = &cobra.Command{
 			return err
 		}
 
-		oidcApi, ssoApi := internal.InitClients(configs[configName])
+		oidcApi, ssoApi, err := internal.InitClients(configs[configName])
+		if err != nil {
+			return err
+		}
+		prompt, err := newPrompt()
+		if err != nil {
+			return err
+		}
+		selector := internal.Selector{Prompt: prompt, RefreshCatalog: refreshCatalog}
 
 		if len(profileNames) >= 1 {
 			if profileNames[0] == "all" {
 				profileNames = utilities.Keys(configs[configName].Profiles)
 			}
 
-			return actionWithSpecifiedProfiles(configs[configName], profileNames, oidcApi, ssoApi, internal.Refresh)
+			if refreshParallel > 1 {
+				// Concurrent refreshes cannot share the terminal, so profiles that need a selection fail instead
+				selector.Prompt = internal.NoInputPrompter{}
+			}
+
+			return actionInParallel(configs[configName], profileNames, oidcApi, ssoApi, selector.Refresh, max(refreshParallel, 1), refreshFailFast)
 		}
 
-		return actionWithUnspecifiedProfiles(configs[configName], oidcApi, ssoApi, internal.Refresh)
+		return actionWithUnspecifiedProfiles(configs[configName], oidcApi, ssoApi, prompt, selector.Refresh)
 	},
 }
 
 func init() {
+	refreshCmd.Flags().BoolVar(&refreshCatalog, "refresh-catalog", false, "Lists accounts and roles from AWS SSO instead of the cached catalog")
+	refreshCmd.Flags().IntVar(&refreshParallel, "parallel", 1, "Number of profiles to refresh concurrently when profiles are given")
+	refreshCmd.Flags().BoolVar(&refreshFailFast, "fail-fast", false, "Stops refreshing further profiles after the first error")
 	rootCmd.AddCommand(refreshCmd)
 }
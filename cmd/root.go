@@ -5,10 +5,15 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/gerdou/awsx/cmd/internal"
 	"github.com/gerdou/awsx/version"
 )
 
 var versionFlag bool
+var noInputFlag bool
+var configDirFlag string
+var answersFlag string
+var recordFlag string
 
 var rootCmd = &cobra.Command{
 	Use:               "awsx",
@@ -35,5 +40,13 @@ func Execute() {
 }
 
 func init() {
+	cobra.OnInitialize(func() {
+		internal.SetConfigDir(configDirFlag)
+	})
+
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Prints awsx's version")
+	rootCmd.PersistentFlags().BoolVar(&noInputFlag, "no-input", false, "Fails instead of prompting for input")
+	rootCmd.PersistentFlags().StringVar(&answersFlag, "answers", "", "Answers prompts from a YAML or JSON file of recorded answers, - reads them from stdin")
+	rootCmd.PersistentFlags().StringVar(&recordFlag, "record", "", "Records the answers to all prompts to a file that can be replayed with --answers")
+	rootCmd.PersistentFlags().StringVar(&configDirFlag, "config-dir", "", "Directory of the awsx configuration and cache, overrides AWSX_HOME")
 }
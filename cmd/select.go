@@ -6,6 +6,10 @@ import (
 	"github.com/gerdou/awsx/utilities"
 )
 
+var selectAccount string
+var selectRole string
+var refreshCatalog bool
+
 var selectCmd = &cobra.Command{
 	Use:               "select",
 	Short:             "Lets you select a profile from available profiles on AWS SSO",
@@ -17,19 +21,30 @@ var selectCmd = &cobra.Command{
 			return err
 		}
 
-		oidcApi, ssoApi := internal.InitClients(configs[configName])
+		oidcApi, ssoApi, err := internal.InitClients(configs[configName])
+		if err != nil {
+			return err
+		}
+		prompt, err := newPrompt()
+		if err != nil {
+			return err
+		}
+		selector := internal.Selector{Prompt: prompt, Account: selectAccount, Role: selectRole, RefreshCatalog: refreshCatalog}
 
 		if len(profileNames) >= 1 {
 			if profileNames[0] == "all" {
 				profileNames = utilities.Keys(configs[configName].Profiles)
 			}
-			return actionWithSpecifiedProfiles(configs[configName], profileNames, oidcApi, ssoApi, internal.Select)
+			return actionWithSpecifiedProfiles(configs[configName], profileNames, oidcApi, ssoApi, selector.Select)
 		}
 
-		return actionWithUnspecifiedProfiles(configs[configName], oidcApi, ssoApi, internal.Select)
+		return actionWithUnspecifiedProfiles(configs[configName], oidcApi, ssoApi, prompt, selector.Select)
 	},
 }
 
 func init() {
+	selectCmd.Flags().StringVar(&selectAccount, "account", "", "Account to select by ID, exact name, glob (prod-*) or regular expression (/^prod-/) on the name")
+	selectCmd.Flags().StringVar(&selectRole, "role", "", "Role to select by exact name, glob or regular expression")
+	selectCmd.Flags().BoolVar(&refreshCatalog, "refresh-catalog", false, "Lists accounts and roles from AWS SSO instead of the cached catalog")
 	rootCmd.AddCommand(selectCmd)
 }
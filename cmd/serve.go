@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var servePort int
+var serveHost string
+var serveToken string
+
+var serveCmd = &cobra.Command{
+	Use:               "serve <config> <profile>",
+	Short:             "Serves credentials on a local container credentials endpoint",
+	Long:              `Serves the credentials of the last used account and role of a profile on a local endpoint compatible with AWS_CONTAINER_CREDENTIALS_FULL_URI. Credentials are refreshed transparently before they expire.`,
+	Example:           "awsx serve default my-profile --port 9911",
+	DisableAutoGenTag: true,
+	Args:              cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, profile, err := readConfigAndProfile(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		if serveToken == "" {
+			serveToken, err = internal.GenerateAuthorizationToken()
+			if err != nil {
+				return err
+			}
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		cache := internal.NewCredentialsCache(config, profile, oidcApi, ssoApi)
+
+		// Retrieve credentials up front so a required login happens before clients connect
+		if _, err = cache.Get(); err != nil {
+			return err
+		}
+
+		address := net.JoinHostPort(serveHost, strconv.Itoa(servePort))
+		return internal.Serve(address, internal.ContainerCredentialsHandler(cache, serveToken), func(addr net.Addr) {
+			log.Printf("Serving credentials for profile %s in %s config on %s", profile.Name, config.Name, addr.String())
+			fmt.Printf("AWS_CONTAINER_CREDENTIALS_FULL_URI=http://%s/\n", addr.String())
+			fmt.Printf("AWS_CONTAINER_AUTHORIZATION_TOKEN=%s\n", serveToken)
+		})
+	},
+}
+
+func init() {
+	serveCmd.Flags().IntVarP(&servePort, "port", "p", 0, "Port to listen on, a free port is chosen when 0")
+	serveCmd.Flags().StringVar(&serveHost, "host", "127.0.0.1", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Authorization token clients must send, generated when empty")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/sso"
-	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
 	"github.com/spf13/cobra"
 	"github.com/gerdou/awsx/cmd/internal"
 	"github.com/gerdou/awsx/utilities"
@@ -52,10 +56,29 @@ func processInputArgsForSelectAndRefresh(cmd *cobra.Command, args []string) (con
 	return configName, configs, profileNames, nil
 }
 
-func actionWithUnspecifiedProfiles(config *internal.Config, oidcApi *ssooidc.Client, ssoApi *sso.Client, action func(*internal.Config, *internal.Profile, *ssooidc.Client, *sso.Client) error) error {
+// newPrompt returns the Prompt used by the commands. It answers from the file given with --answers, fails
+// instead of prompting with --no-input and records the answers to the file given with --record.
+func newPrompt() (internal.Prompt, error) {
+	var prompt internal.Prompt = internal.Prompter{}
+	if answersFlag != "" {
+		scripted, err := internal.NewScriptedPrompter(answersFlag)
+		if err != nil {
+			return nil, err
+		}
+		prompt = scripted
+	} else if noInputFlag {
+		prompt = internal.NoInputPrompter{}
+	}
+
+	if recordFlag != "" {
+		prompt = &internal.RecordingPrompter{Prompter: prompt, FileName: recordFlag}
+	}
+	return prompt, nil
+}
+
+func actionWithUnspecifiedProfiles(config *internal.Config, oidcApi internal.OidcClient, ssoApi internal.SsoClient, prompt internal.Prompt, action func(*internal.Config, *internal.Profile, internal.OidcClient, internal.SsoClient) error) error {
 	var selectedProfiles []*internal.Profile
 	if len(config.Profiles) > 1 {
-		prompt := internal.Prompter{}
 		profiles := utilities.Keys(config.Profiles)
 		slices.Sort(profiles)
 		indexes, err := prompt.MultiSelect("Select the profile", profiles, nil)
@@ -99,7 +122,7 @@ func actionWithUnspecifiedProfiles(config *internal.Config, oidcApi *ssooidc.Cli
 	return errors.Join(errs...)
 }
 
-func actionWithSpecifiedProfiles(config *internal.Config, profileNames []string, oidcApi *ssooidc.Client, ssoApi *sso.Client, action func(*internal.Config, *internal.Profile, *ssooidc.Client, *sso.Client) error) error {
+func actionWithSpecifiedProfiles(config *internal.Config, profileNames []string, oidcApi internal.OidcClient, ssoApi internal.SsoClient, action func(*internal.Config, *internal.Profile, internal.OidcClient, internal.SsoClient) error) error {
 	var validProfileNames []string
 	var errs []error
 	for _, profile := range config.Profiles {
@@ -114,3 +137,109 @@ func actionWithSpecifiedProfiles(config *internal.Config, profileNames []string,
 
 	return errors.Join(errs...)
 }
+
+// readConfigAndProfile looks up an existing config and profile without falling back to the interactive configuration.
+func readConfigAndProfile(configName string, profileName string) (*internal.Config, *internal.Profile, error) {
+	configs, err := internal.ReadInternalConfig()
+	if err != nil {
+		return nil, nil, errors.New("no configuration found, run \"awsx config\" first")
+	}
+
+	config, exists := configs[configName]
+	if !exists {
+		return nil, nil, fmt.Errorf("config \"%s\" does not exist", configName)
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists || profile == nil {
+		return nil, nil, fmt.Errorf("profile \"%s\" does not exist in config \"%s\"", profileName, configName)
+	}
+
+	return config, profile, nil
+}
+
+type profileResult struct {
+	profile   string
+	err       error
+	skipped   bool
+	duration  time.Duration
+	expiresAt time.Time
+}
+
+// actionInParallel runs the action for the named profiles with at most parallel concurrent runs and prints a summary.
+// With parallel 1 the profiles run one after another in the order of their names. The access token is retrieved once
+// up front and shared by all runs. With failFast no further runs are started after the first error.
+func actionInParallel(config *internal.Config, profileNames []string, oidcApi internal.OidcClient, ssoApi internal.SsoClient, action func(*internal.Config, *internal.Profile, internal.OidcClient, internal.SsoClient) error, parallel int, failFast bool) error {
+	var profiles []*internal.Profile
+	for _, profileName := range profileNames {
+		if profile, exists := config.Profiles[profileName]; exists {
+			profiles = append(profiles, profile)
+		}
+	}
+	slices.SortFunc(profiles, func(a, b *internal.Profile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if _, err := internal.ProcessClientInformation(config, oidcApi); err != nil {
+		return err
+	}
+
+	results := make([]profileResult, len(profiles))
+	semaphore := make(chan struct{}, parallel)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		semaphore <- struct{}{}
+		if failFast && failed.Load() {
+			<-semaphore
+			results[i] = profileResult{profile: profile.Name, skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, profile *internal.Profile) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			start := time.Now()
+			err := action(config, profile, oidcApi, ssoApi)
+			results[i] = profileResult{profile: profile.Name, err: err, duration: time.Since(start)}
+			if err != nil {
+				failed.Store(true)
+				return
+			}
+			results[i].expiresAt, _ = internal.ReadAwsCredentialsExpiration(profile.Name)
+		}(i, profile)
+	}
+	wg.Wait()
+
+	printProfileResults(results)
+
+	var errs []error
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.profile, result.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func printProfileResults(results []profileResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PROFILE\tSTATUS\tDURATION\tEXPIRES")
+	for _, result := range results {
+		status, expires := "ok", "-"
+		switch {
+		case result.skipped:
+			status = "skipped"
+		case result.err != nil:
+			status = "failed"
+		case !result.expiresAt.IsZero():
+			expires = result.expiresAt.Local().Format(time.DateTime)
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", result.profile, status, result.duration.Round(time.Millisecond), expires)
+	}
+	_ = writer.Flush()
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// statusExpiredExitCode is returned by the status command when a profile needs a new login or has expired credentials
+const statusExpiredExitCode = 2
+
+var statusOutput string
+
+var statusCmd = &cobra.Command{
+	Use:               "status",
+	Short:             "Shows token and credential expiry across all configs",
+	Long:              `Shows the access token, client registration and credential expiry and the last used account and role of every profile in every config. Exits with code 2 when a profile needs a new login or has expired credentials.`,
+	Example:           "awsx status -o json",
+	DisableAutoGenTag: true,
+	Args:              cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := internal.CollectStatus()
+		if err != nil {
+			return fmt.Errorf("no configuration found: %w", err)
+		}
+
+		switch statusOutput {
+		case "table":
+			printStatusTable(statuses)
+		case "json":
+			content, err := json.MarshalIndent(statuses, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(content))
+		case "yaml":
+			content, err := yaml.Marshal(statuses)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(content))
+		default:
+			return fmt.Errorf("unsupported output \"%s\", expected one of: table, json, yaml", statusOutput)
+		}
+
+		for _, status := range statuses {
+			if status.Expired {
+				os.Exit(statusExpiredExitCode)
+			}
+		}
+		return nil
+	},
+}
+
+func printStatusTable(statuses []internal.ProfileStatus) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "CONFIG\tPROFILE\tACCOUNT\tROLE\tACCESS TOKEN\tREGISTRATION\tCREDENTIALS")
+	for _, status := range statuses {
+		account := "-"
+		if status.AccountId != "" {
+			account = fmt.Sprintf("%s (%s)", status.AccountName, status.AccountId)
+		}
+		role := "-"
+		if status.Role != "" {
+			role = status.Role
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			status.Config,
+			status.Profile,
+			account,
+			role,
+			formatAccessTokenExpiry(status),
+			formatStatusExpiry(status.ClientRegistrationExpiresAt),
+			formatStatusExpiry(status.CredentialsExpireAt),
+		)
+	}
+	_ = writer.Flush()
+}
+
+func formatAccessTokenExpiry(status internal.ProfileStatus) string {
+	expiry := formatStatusExpiry(status.AccessTokenExpiresAt)
+	if status.AccessTokenRefreshable && status.AccessTokenExpiresAt != nil && status.AccessTokenExpiresAt.Before(time.Now()) {
+		expiry += " (refreshable)"
+	}
+	return expiry
+}
+
+func formatStatusExpiry(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return "-"
+	}
+	if expiresAt.Before(time.Now()) {
+		return "expired " + expiresAt.Local().Format(time.DateTime)
+	}
+	return "valid until " + expiresAt.Local().Format(time.DateTime)
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "table", "Output format, one of: table, json, yaml")
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gerdou/awsx/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var syncOptions = internal.SyncOptions{}
+
+var syncCmd = &cobra.Command{
+	Use:               "sync [config]",
+	Short:             "Generates one profile per account and role from the SSO directory",
+	Long:              `Generates one profile with a default account for every account and role available in the SSO directory. Running it again adds new accounts and roles and flags or prunes removed ones. Profiles not created by sync are never changed.`,
+	Example:           "awsx sync default --template '{{.AccountName | slug}}-{{.RoleName}}' --exclude 'sandbox-*' --region eu-west-1",
+	DisableAutoGenTag: true,
+	Args:              cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configName := "default"
+		if len(args) > 0 {
+			configName = args[0]
+		}
+
+		configs, err := internal.ReadInternalConfig()
+		if err != nil {
+			return fmt.Errorf("no configuration found, run \"awsx config %s\" first", configName)
+		}
+
+		config, exists := configs[configName]
+		if !exists {
+			return fmt.Errorf("config \"%s\" does not exist", configName)
+		}
+
+		oidcApi, ssoApi, err := internal.InitClients(config)
+		if err != nil {
+			return err
+		}
+		result, err := internal.Sync(config, oidcApi, ssoApi, syncOptions)
+		if err != nil {
+			return err
+		}
+
+		logSyncedProfiles("Added", result.Added)
+		logSyncedProfiles("Skipped hand-edited", result.Skipped)
+		logSyncedProfiles("Pruned", result.Pruned)
+		if len(result.Stale) > 0 {
+			logSyncedProfiles("Stale", result.Stale)
+			log.Println("Run again with --prune to remove stale profiles")
+		}
+		for _, err := range result.Invalid {
+			log.Println(err)
+		}
+		if len(result.Invalid) > 0 {
+			return fmt.Errorf("%d profile(s) have an invalid name, change the template", len(result.Invalid))
+		}
+		return nil
+	},
+}
+
+func logSyncedProfiles(label string, profileNames []string) {
+	if len(profileNames) == 0 {
+		return
+	}
+	log.Printf("%s %d profile(s): %s\n", label, len(profileNames), strings.Join(profileNames, ", "))
+}
+
+func init() {
+	syncCmd.Flags().StringVarP(&syncOptions.Template, "template", "t", internal.DefaultSyncTemplate, "Template of the profile names, with .AccountName, .AccountId, .RoleName, .Config and the slug, lower and upper functions")
+	syncCmd.Flags().StringSliceVar(&syncOptions.Include, "include", []string{}, "Only sync accounts matching these IDs, names or patterns")
+	syncCmd.Flags().StringSliceVar(&syncOptions.Exclude, "exclude", []string{}, "Skip accounts matching these IDs, names or patterns")
+	syncCmd.Flags().StringSliceVar(&syncOptions.Roles, "role", []string{}, "Only sync roles matching these names or patterns")
+	syncCmd.Flags().StringVarP(&syncOptions.Region, "region", "r", "", "Region of new profiles, defaults to the SSO region")
+	syncCmd.Flags().BoolVar(&syncOptions.Prune, "prune", false, "Removes synced profiles whose account or role no longer exists")
+	rootCmd.AddCommand(syncCmd)
+}